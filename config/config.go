@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	starnetRedis "starnet/starnet/pkg/redis"
 
@@ -11,65 +12,69 @@ import (
 )
 
 type Config struct {
-	Listen string `mapstructure:"listen"`
+	Listen          string        `mapstructure:"listen"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"` // drain of requests and websockets on SIGTERM, defaults to 30s
 
 	Upstream struct {
 		Eth struct {
-			Http   string `mapstructure:"http"`
-			Ws     string `mapstructure:"ws"`
-			Erigon struct {
-				Http string `mapstructure:"http"`
-				Ws   string `mapstructure:"ws"`
+			Http            string           `mapstructure:"http"`
+			Ws              string           `mapstructure:"ws"`
+			Relays          []string         `mapstructure:"relays"` // public relays which also get every eth_sendRawTransaction
+			HttpClient      HttpClientConfig `mapstructure:"http_client"`
+			Auth            UpstreamAuth     `mapstructure:"auth"`     // of the http and ws upstreams
+			Sanitize        []SanitizeRule   `mapstructure:"sanitize"` // applied after the built in rules
+			UpstreamRouting `mapstructure:",squash"`
+			Erigon          struct {
+				Http string       `mapstructure:"http"`
+				Ws   string       `mapstructure:"ws"`
+				Auth UpstreamAuth `mapstructure:"auth"`
 			} `mapstructure:"erigon"`
+			Private struct {
+				Http           string   `mapstructure:"http"`            // private transaction relay, disabled when empty
+				ApiKeys        []string `mapstructure:"api_keys"`        // keys whose transactions always go to the relay
+				FallbackBlocks int64    `mapstructure:"fallback_blocks"` // broadcast publicly when not mined after this many blocks, 0 never
+			} `mapstructure:"private"`
 		} `mapstructure:"eth"`
 		Polygon struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
+			Http            string           `mapstructure:"http"`
+			Ws              string           `mapstructure:"ws"`
+			HttpClient      HttpClientConfig `mapstructure:"http_client"`
+			Auth            UpstreamAuth     `mapstructure:"auth"`
+			Sanitize        []SanitizeRule   `mapstructure:"sanitize"` // applied after the built in rules
+			UpstreamRouting `mapstructure:",squash"`
 		} `mapstructure:"polygon"`
 		Arbitrum struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
+			Http            string           `mapstructure:"http"`
+			Ws              string           `mapstructure:"ws"`
+			HttpClient      HttpClientConfig `mapstructure:"http_client"`
+			Auth            UpstreamAuth     `mapstructure:"auth"`
+			Sanitize        []SanitizeRule   `mapstructure:"sanitize"` // applied after the built in rules
+			UpstreamRouting `mapstructure:",squash"`
 		} `mapstructure:"arbitrum"`
 		Solana struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
+			Http            string           `mapstructure:"http"`
+			Ws              string           `mapstructure:"ws"`
+			HttpClient      HttpClientConfig `mapstructure:"http_client"`
+			Auth            UpstreamAuth     `mapstructure:"auth"`
+			Sanitize        []SanitizeRule   `mapstructure:"sanitize"` // applied after the built in rules
+			UpstreamRouting `mapstructure:",squash"`
 		} `mapstructure:"solana"`
 		Hsc struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
+			Http            string           `mapstructure:"http"`
+			Ws              string           `mapstructure:"ws"`
+			HttpClient      HttpClientConfig `mapstructure:"http_client"`
+			Auth            UpstreamAuth     `mapstructure:"auth"`
+			Sanitize        []SanitizeRule   `mapstructure:"sanitize"` // applied after the built in rules
+			UpstreamRouting `mapstructure:",squash"`
 		} `mapstructure:"hsc"`
-		Cosmos struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"cosmos"`
-		Evmos struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"evmos"`
-		Kava struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"kava"`
-		Juno struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"juno"`
-		Umee struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"umee"`
-		Gravity struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"gravity"`
-		OKC struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"okc"`
-		IRISnet struct {
-			Http string `mapstructure:"http"`
-			Ws   string `mapstructure:"ws"`
-		} `mapstructure:"irisnet"`
+		Cosmos  CosmosUpstream `mapstructure:"cosmos"`
+		Evmos   CosmosUpstream `mapstructure:"evmos"`
+		Kava    CosmosUpstream `mapstructure:"kava"`
+		Juno    CosmosUpstream `mapstructure:"juno"`
+		Umee    CosmosUpstream `mapstructure:"umee"`
+		Gravity CosmosUpstream `mapstructure:"gravity"`
+		OKC     CosmosUpstream `mapstructure:"okc"`
+		IRISnet CosmosUpstream `mapstructure:"irisnet"`
 	} `mapstructure:"upstream"`
 
 	Log struct {
@@ -95,6 +100,85 @@ type Config struct {
 	Redis []starnetRedis.Conf `mapstructure:"redis"`
 }
 
+// UpstreamRouting sends the json-rpc calls matching a route to a named pool instead of the chain upstream
+type UpstreamRouting struct {
+	Pools  map[string]UpstreamPool `mapstructure:"pools"`
+	Routes []UpstreamRoute         `mapstructure:"routes"` // the first matching route wins
+
+	// Hedge are http upstreams mirroring the chain upstream, read calls it answers slower than usual also go to one
+	Hedge []string `mapstructure:"hedge"`
+}
+
+// UpstreamPool is a named group of upstream endpoints, e.g. archive, trace, full or light nodes
+type UpstreamPool struct {
+	Http string       `mapstructure:"http"`
+	Ws   string       `mapstructure:"ws"` // calls over ws use the chain upstream when empty
+	Auth UpstreamAuth `mapstructure:"auth"`
+}
+
+// UpstreamRoute matches calls by method name, a trailing * matches any suffix, e.g. trace_*
+type UpstreamRoute struct {
+	Methods []string `mapstructure:"methods"`
+	Pool    string   `mapstructure:"pool"`
+
+	// MinBlockDepth only matches evm calls reading a block at least this many blocks behind the head, earliest
+	// and block hashes unknown to the head tracker always match, latest and the other tags never do
+	MinBlockDepth uint64 `mapstructure:"min_block_depth"`
+}
+
+// CosmosUpstream is a cosmos sdk chain, Http and Ws serve tendermint rpc and Lcd serves the rest api
+type CosmosUpstream struct {
+	Http       string   `mapstructure:"http"`
+	Ws         string   `mapstructure:"ws"`
+	Lcd        string   `mapstructure:"lcd"`
+	Grpc       string   `mapstructure:"grpc"`        // upstream host:port
+	GrpcTls    bool     `mapstructure:"grpc_tls"`    // use tls to the upstream
+	GrpcListen string   `mapstructure:"grpc_listen"` // local grpc listener, grpc-web is served by the http server and only grpc-web when empty
+	Relays     []string `mapstructure:"relays"`      // public tendermint rpc endpoints which also get every broadcast_tx_*
+
+	HttpClient HttpClientConfig `mapstructure:"http_client"` // of the http and lcd calls
+	Auth       UpstreamAuth     `mapstructure:"auth"`        // of the http, ws and lcd upstreams
+	Sanitize   []SanitizeRule   `mapstructure:"sanitize"`    // applied after the built in rules
+}
+
+// UpstreamAuth is the credential sent to an upstream node, it is never forwarded to clients nor logged.
+// Bearer replaces basic auth in the Authorization header and a jwt replaces both.
+type UpstreamAuth struct {
+	Username string `mapstructure:"username" toml:"username"` // basic auth
+	Password string `mapstructure:"password" toml:"password"`
+	Bearer   string `mapstructure:"bearer" toml:"bearer"` // static token
+
+	// JwtSecret is the hex encoded 32 byte secret of an engine api style jwt, signed for every request
+	JwtSecret     string `mapstructure:"jwt_secret" toml:"jwt_secret"`
+	JwtSecretFile string `mapstructure:"jwt_secret_file" toml:"jwt_secret_file"` // file holding the JwtSecret, e.g. geth's jwtsecret
+
+	Headers map[string]string `mapstructure:"headers" toml:"headers"` // e.g. an api key header of a provider
+}
+
+// HttpClientConfig is the transport of the http calls to the upstreams of a chain, zero values use the defaults.
+// Durations are strings like "5s".
+type HttpClientConfig struct {
+	// RequestTimeout bounds a client request with all its upstream calls, 0 keeps the default of the handler:
+	// 5s for the upstream sections and only the client connection for the rpc config chains
+	RequestTimeout time.Duration `mapstructure:"request_timeout" toml:"request_timeout"`
+
+	DialTimeout           time.Duration `mapstructure:"dial_timeout" toml:"dial_timeout"`                   // defaults to 5s
+	TlsHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout" toml:"tls_handshake_timeout"` // defaults to 5s
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout" toml:"response_header_timeout"`
+	IdleConnTimeout       time.Duration `mapstructure:"idle_conn_timeout" toml:"idle_conn_timeout"` // defaults to 90s
+
+	MaxIdleConns        int `mapstructure:"max_idle_conns" toml:"max_idle_conns"`                   // defaults to 256
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host" toml:"max_idle_conns_per_host"` // defaults to 64
+	MaxConnsPerHost     int `mapstructure:"max_conns_per_host" toml:"max_conns_per_host"`           // 0 is unlimited
+
+	DisableHttp2 bool   `mapstructure:"disable_http2" toml:"disable_http2"`
+	Proxy        string `mapstructure:"proxy" toml:"proxy"` // http or socks5 proxy url, HTTP_PROXY and HTTPS_PROXY are used when empty
+
+	CaFile   string `mapstructure:"ca_file" toml:"ca_file"`     // pem certificates trusted in addition to the system roots
+	CertFile string `mapstructure:"cert_file" toml:"cert_file"` // client certificate for mutual tls
+	KeyFile  string `mapstructure:"key_file" toml:"key_file"`
+}
+
 func LoadConfig(configFile string) (*Config, error) {
 	viper.SetConfigFile(configFile)
 	viper.SetConfigType("toml")
@@ -112,13 +196,41 @@ func LoadConfig(configFile string) (*Config, error) {
 	return cfg, nil
 }
 
+const (
+	NodePoolRead  = "read"
+	NodePoolWrite = "write"
+)
+
+// LoadBalanceRoundRobin spreads the requests of a pool over its healthy nodes, by default the first healthy node serves them
+const LoadBalanceRoundRobin = "round_robin"
+
 type RpcNode struct {
-	Name       string `toml:"name"`
-	Http       string `toml:"http"`
-	Ws         string `toml:"ws"`
-	ExtraWrite string `toml:"extra_write"` // tron network only
+	Name       string       `toml:"name"`
+	Http       string       `toml:"http"`
+	Ws         string       `toml:"ws"`
+	ExtraWrite string       `toml:"extra_write"` // a second api family of the node, e.g. the tron wallet api
+	Pool       string       `toml:"pool"`        // read, write or empty for both
+	Auth       UpstreamAuth `toml:"auth"`
+
+	// Username and Password are the former basic auth of the node, LoadRPCConfig moves them to Auth
+	Username string `toml:"username"`
+	Password string `toml:"password"`
 }
 
+// HealthCheck describes the request used to read the block number of a node endpoint
+type HealthCheck struct {
+	Method           string `toml:"method"` // GET or POST
+	Path             string `toml:"path"`   // appended to the endpoint url
+	Body             string `toml:"body"`
+	ResultExpression string `toml:"result_expression"` // jq, defaults to block_number_result_expression
+}
+
+const (
+	DefaultMaxBehindBlocks             int64 = 10
+	DefaultBlockNumberResultExpression       = ".result"
+	DefaultMaxRequestBytes             int64 = 10 << 20 // 10MB
+)
+
 type RpcConfig struct {
 	ApiKey            string
 	HealthPushgateway string
@@ -127,12 +239,39 @@ type RpcConfig struct {
 
 type ChainConfig struct {
 	ChainName                   string
-	ChainType                   string    `toml:"chain_type"`
-	MaxBehindBlocks             int64     `toml:"max_behind_blocks"`
-	BlockNumberMethod           string    `toml:"block_number_method"`
-	BlockNumberResultExtractor  string    `toml:"block_number_result_extractor"`
-	BlockNumberResultExpression string    `toml:"block_number_result_expression"`
-	Nodes                       []RpcNode `toml:"nodes"`
+	ChainType                   string           `toml:"chain_type"`
+	MaxBehindBlocks             int64            `toml:"max_behind_blocks"`
+	BlockNumberMethod           string           `toml:"block_number_method"`
+	BlockNumberResultExtractor  string           `toml:"block_number_result_extractor"`
+	BlockNumberResultExpression string           `toml:"block_number_result_expression"`
+	BlackMethods                []string         `toml:"black_methods"` // json-rpc chains only
+	HttpHealth                  HealthCheck      `toml:"http_health"`
+	ExtraWriteHealth            HealthCheck      `toml:"extra_write_health"`
+	WritePaths                  []string         `toml:"write_paths"`       // non GET requests on these paths go to the write pool
+	Sanitize                    []SanitizeRule   `toml:"sanitize"`          // json-rpc chains only
+	BroadcastMethods            []string         `toml:"broadcast_methods"` // json-rpc calls sent to every healthy write node
+	BroadcastRelays             []string         `toml:"broadcast_relays"`  // public relays which also get every broadcast
+	LoadBalance                 string           `toml:"load_balance"`      // "round_robin" or empty for the first healthy node
+	StickySeconds               int64            `toml:"sticky_seconds"`    // round robin keeps a client ip on its node for this long, not an api key as the rpc routes share one
+	PinLatest                   bool             `toml:"pin_latest"`        // evm only, latest is rewritten to the consensus head
+	Quorum                      []QuorumRule     `toml:"quorum"`            // json-rpc chains only
+	Hedge                       bool             `toml:"hedge"`             // read requests slower than the p95 latency also go to a second node
+	HttpClient                  HttpClientConfig `toml:"http_client"`
+	MaxRequestBytes             int64            `toml:"max_request_bytes"` // larger request bodies are rejected
+	Nodes                       []RpcNode        `toml:"nodes"`
+}
+
+// QuorumRule answers calls to Methods with the result most of Nodes healthy nodes agree on
+type QuorumRule struct {
+	Methods []string `toml:"methods"`
+	Nodes   int      `toml:"nodes"` // queried in parallel, defaults to 3
+}
+
+// SanitizeRule rewrites the responses of calls to Methods before they are sent to the client
+type SanitizeRule struct {
+	Methods []string `toml:"methods" mapstructure:"methods"` // json-rpc methods or tendermint paths
+	Redact  []string `toml:"redact" mapstructure:"redact"`   // json pointers blanked to "", a * segment matches every array item or object key
+	Jq      string   `toml:"jq" mapstructure:"jq"`           // applied to the whole response after the redactions
 }
 
 func LoadRPCConfig(data string) (*RpcConfig, error) {
@@ -154,15 +293,15 @@ func LoadRPCConfig(data string) (*RpcConfig, error) {
 		if chainName == "apikey" || chainName == "health_pushgateway" {
 			continue
 		}
-		var defaultMaxBehindBlocks int64 = 10
 		cfg := ChainConfig{
 			ChainName: chainName,
-			// Default values
+			// Default values, chain type specific defaults are applied by chaintype.Configure
 			ChainType:                   "evm",
-			MaxBehindBlocks:             defaultMaxBehindBlocks,
+			MaxBehindBlocks:             DefaultMaxBehindBlocks,
 			BlockNumberMethod:           "",
 			BlockNumberResultExtractor:  "jq",
-			BlockNumberResultExpression: ".result",
+			BlockNumberResultExpression: DefaultBlockNumberResultExpression,
+			MaxRequestBytes:             DefaultMaxRequestBytes,
 		}
 		buf := buffer.Buffer{}
 		if err := toml.NewEncoder(&buf).Encode(chainConfig); err != nil {
@@ -172,21 +311,12 @@ func LoadRPCConfig(data string) (*RpcConfig, error) {
 		if err := toml.Unmarshal(buf.Bytes(), &cfg); err != nil {
 			return nil, err
 		}
-
-		switch cfg.ChainType {
-		case "evm":
-			cfg.BlockNumberMethod = "eth_blockNumber"
-		case "svm":
-			cfg.BlockNumberMethod = "getBlockHeight"
-		case "aptos":
-			cfg.BlockNumberResultExpression = ".ledger_version"
-		case "tron":
-			cfg.BlockNumberMethod = "eth_blockNumber"
-			if cfg.MaxBehindBlocks == defaultMaxBehindBlocks {
-				cfg.MaxBehindBlocks = 40 // 120 seconds
+		for i := range cfg.Nodes {
+			node := &cfg.Nodes[i]
+			if node.Auth.Username == "" && node.Auth.Password == "" {
+				node.Auth.Username, node.Auth.Password = node.Username, node.Password
 			}
-		default:
-			return nil, fmt.Errorf("unsupported chain type: %s", cfg.ChainType)
+			node.Username, node.Password = "", ""
 		}
 
 		rpcConfig.Chains = append(rpcConfig.Chains, cfg)
@@ -22,3 +22,29 @@ block_number_result_expression = ".result"
 	}
 	fmt.Printf("%+v\n", rpcConfig)
 }
+
+func TestLoadRPCConfigNodeAuth(t *testing.T) {
+	rpcConfig, err := LoadRPCConfig(`
+apikey = "key"
+
+[eth]
+[[eth.nodes]]
+http = "https://node1"
+username = "user"
+password = "secret"
+
+[[eth.nodes]]
+http = "https://node2"
+auth = { bearer = "token", headers = { "X-Api-Key" = "key" } }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := rpcConfig.Chains[0].Nodes
+	if nodes[0].Auth.Username != "user" || nodes[0].Auth.Password != "secret" || nodes[0].Password != "" {
+		t.Errorf("expected the basic auth to move to auth, got %+v", nodes[0])
+	}
+	if nodes[1].Auth.Bearer != "token" || nodes[1].Auth.Headers["X-Api-Key"] != "key" {
+		t.Errorf("unexpected auth %+v", nodes[1].Auth)
+	}
+}
@@ -1,6 +1,13 @@
 package app
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
 	"starnet/chain-api/config"
 	ratelimitv1 "starnet/chain-api/ratelimit/v1"
 	serviceInterface "starnet/chain-api/service/interface"
@@ -38,31 +45,51 @@ type App struct {
 
 	CosmosHttpHandler HttpHandler
 	CosmosWsHandler   WsHandler
+	CosmosLcdHandler  LcdHandler
 
 	EvmosHttpHandler HttpHandler
 	EvmosWsHandler   WsHandler
+	EvmosLcdHandler  LcdHandler
 
 	KavaHttpHandler HttpHandler
 	KavaWsHandler   WsHandler
+	KavaLcdHandler  LcdHandler
 
 	// 	juno
 	JunoHttpHandler HttpHandler
 	JunoWsHandler   WsHandler
+	JunoLcdHandler  LcdHandler
 
 	// umee
 	UmeeHttpHandler HttpHandler
 	UmeeWsHandler   WsHandler
+	UmeeLcdHandler  LcdHandler
 
 	GravityHttpHandler HttpHandler
 	GravityWsHandler   WsHandler
+	GravityLcdHandler  LcdHandler
 
 	// okc
 	OKCHttpHandler HttpHandler
 	OKCWsHandler   WsHandler
+	OKCLcdHandler  LcdHandler
 
 	// irisnet
 	IRISnetHttpHandler HttpHandler
 	IRISnetWsHandler   WsHandler
+	IRISnetLcdHandler  LcdHandler
+
+	// GrpcHandlers cosmos chains with a grpc upstream, keyed by the chain route name
+	GrpcHandlers map[string]GrpcHandler
+
+	// HeadTrackers evm chains keyed by chain name, shared by the legacy and the rpc config handlers
+	HeadTrackers map[string]HeadTracker
+
+	// Websockets open client websockets, drained on shutdown
+	Websockets *Websockets
+
+	// Background goroutines using redis after their request, stopped on shutdown before redis is closed
+	Background *Background
 
 	// ipfs
 	IPFSHandler IPFSHandler
@@ -70,9 +97,83 @@ type App struct {
 	IPFSSrv serviceInterface.IpfsService
 }
 
+// DefaultShutdownTimeout is the drain time of a shutdown unless shutdown_timeout is configured
+const DefaultShutdownTimeout = time.Second * 30
+
+// Start runs the servers until SIGTERM or SIGINT, then shuts them down gracefully
 func (a *App) Start() {
-	err := a.HttpServer.Start(a.Config.Listen)
-	if err != nil {
+	for name, h := range a.GrpcHandlers {
+		go func(name string, h GrpcHandler) {
+			if err := h.Serve(); err != nil {
+				a.Logger.Error("failed to run grpc server", zap.String("chain", name), zap.Error(err))
+			}
+		}(name, h)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- a.HttpServer.Start(a.Config.Listen)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-serveErr:
 		a.Logger.Error("failed to run http server", zap.Error(err))
+	case sig := <-signals:
+		a.Logger.Info("shutting down", zap.String("signal", sig.String()))
+	}
+	a.Shutdown()
+}
+
+// Shutdown stops accepting connections, asks the websocket clients to reconnect and waits for the in-flight
+// requests with their upstream calls until the shutdown timeout. Redis and MySQL are closed once drained.
+func (a *App) Shutdown() {
+	timeout := a.Config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := a.HttpServer.Shutdown(ctx); err != nil {
+			a.Logger.Warn("in-flight requests cut off", zap.Error(err))
+		}
+	}()
+	for _, h := range a.GrpcHandlers {
+		wg.Add(1)
+		go func(h GrpcHandler) {
+			defer wg.Done()
+			h.Shutdown(ctx)
+		}(h)
+	}
+
+	a.Websockets.GoAway()
+	if err := a.Websockets.Wait(ctx); err != nil {
+		a.Logger.Warn("websockets cut off", zap.Error(err))
+		a.Websockets.Close()
+	}
+	wg.Wait()
+
+	// usage counters are written while serving their request, they are all in redis once the requests are done.
+	// The goroutines outliving their request are stopped before redis is closed under them.
+	if err := a.Background.Stop(ctx); err != nil {
+		a.Logger.Warn("background work cut off", zap.Error(err))
+	}
+	if err := a.Rdb.Close(); err != nil {
+		a.Logger.Error("failed to close redis", zap.Error(err))
+	}
+	if a.DB != nil {
+		if sqlDB, err := a.DB.DB(); err == nil {
+			if err = sqlDB.Close(); err != nil {
+				a.Logger.Error("failed to close mysql", zap.Error(err))
+			}
+		}
 	}
+	a.Logger.Info("shut down")
+	_ = a.Logger.Sync()
 }
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"context"
+	"sync"
+)
+
+// Background tracks the goroutines outliving their request which use redis, e.g. broadcast outcome records,
+// private transaction fallbacks and reorg cache invalidations, so the shutdown closes redis after them. A nil
+// Background tracks nothing.
+type Background struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex   sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func NewBackground() *Background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Background{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a goroutine, ctx ends when the shutdown starts and fn should return soon after. ok is false once
+// the shutdown started, fn is not run.
+func (b *Background) Go(fn func(ctx context.Context)) (ok bool) {
+	if b == nil {
+		go fn(context.Background())
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.stopped {
+		return false
+	}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn(b.ctx)
+	}()
+	return true
+}
+
+// Stop refuses new goroutines, ends the ctx of the running ones and returns once they are done or ctx ends
+func (b *Background) Stop(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	b.stopped = true
+	b.mutex.Unlock()
+	b.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
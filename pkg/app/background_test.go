@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackground(t *testing.T) {
+	b := NewBackground()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	if !b.Go(func(ctx context.Context) {
+		<-release
+		close(finished)
+	}) {
+		t.Fatal("expected the goroutine to run")
+	}
+	cancelled := make(chan struct{})
+	b.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := b.Stop(ctx); err == nil {
+		t.Error("expected Stop to wait for the running goroutine")
+	}
+	<-cancelled
+	if b.Go(func(ctx context.Context) { t.Error("expected no goroutine after Stop") }) {
+		t.Error("expected Go to refuse after Stop")
+	}
+
+	close(release)
+	if err := b.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("expected Stop to return after the goroutine")
+	}
+}
+
+func TestNilBackground(t *testing.T) {
+	var b *Background
+	done := make(chan struct{})
+	if !b.Go(func(ctx context.Context) { close(done) }) {
+		t.Fatal("expected a nil background to run the goroutine")
+	}
+	<-done
+	if err := b.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -13,6 +15,27 @@ type WsHandler interface {
 	Ws(ctx echo.Context) error
 }
 
+// LcdHandler serves the cosmos sdk rest api
+type LcdHandler interface {
+	Lcd(ctx echo.Context) error
+}
+
+// GrpcHandler serves the grpc listener and the grpc-web route of a cosmos chain
+type GrpcHandler interface {
+	Serve() error
+	Shutdown(ctx context.Context) // stops serving, running calls are cancelled when ctx ends
+	GrpcWeb(ctx echo.Context) error
+}
+
+// HeadTracker follows the head of an evm chain, see headtracker.Tracker
+type HeadTracker interface {
+	Latest() uint64
+	Safe() uint64
+	Finalized() uint64
+	Hash(number uint64) (string, bool)
+	OnReorg(fn func(number uint64))
+}
+
 type IPFSHandler interface {
 	Proxy(ctx echo.Context) error
 }
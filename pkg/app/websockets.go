@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Websockets tracks the open client websockets for the shutdown, http.Server.Shutdown does not wait for
+// hijacked connections. A nil Websockets tracks nothing.
+type Websockets struct {
+	mutex   sync.Mutex
+	closing bool
+	next    uint64
+	conns   map[uint64]trackedWebsocket
+	wg      sync.WaitGroup
+}
+
+type trackedWebsocket struct {
+	goAway func() // nil when the connection can not take a close frame, e.g. a raw relay
+	close  func()
+}
+
+func NewWebsockets() *Websockets {
+	return &Websockets{conns: map[uint64]trackedWebsocket{}}
+}
+
+// Track registers an open websocket until done is called. On shutdown goAway asks its client to reconnect and
+// close ends it when still open at the deadline. ok is false once the shutdown started, the websocket is refused.
+func (w *Websockets) Track(goAway, close func()) (done func(), ok bool) {
+	if w == nil {
+		return func() {}, true
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closing {
+		return nil, false
+	}
+
+	id := w.next
+	w.next++
+	w.conns[id] = trackedWebsocket{goAway: goAway, close: close}
+	w.wg.Add(1)
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			w.mutex.Lock()
+			delete(w.conns, id)
+			w.mutex.Unlock()
+			w.wg.Done()
+		})
+	}, true
+}
+
+// GoAway refuses new websockets and asks the clients of the open ones to reconnect
+func (w *Websockets) GoAway() {
+	if w == nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closing = true
+	for _, conn := range w.conns {
+		if conn.goAway != nil {
+			go conn.goAway()
+		}
+	}
+}
+
+// Wait returns once every websocket is done or ctx ends
+func (w *Websockets) Wait(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close ends the websockets still open
+func (w *Websockets) Close() {
+	if w == nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, conn := range w.conns {
+		conn.close()
+	}
+}
+
+// GoAwayMessage is the close frame sent to websocket clients on shutdown, 1012 tells them to reconnect
+var GoAwayMessage = websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting, reconnect")
+
+// GoAway sends GoAwayMessage to conn, it may be called alongside the writer of conn
+func GoAway(conn *websocket.Conn) {
+	_ = conn.WriteControl(websocket.CloseMessage, GoAwayMessage, time.Now().Add(time.Second))
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebsockets(t *testing.T) {
+	w := NewWebsockets()
+	var goAways, closes atomic.Int32
+	goAway := func() { goAways.Add(1) }
+	closeFn := func() { closes.Add(1) }
+
+	done1, ok := w.Track(goAway, closeFn)
+	if !ok {
+		t.Fatal("expected the websocket to be tracked")
+	}
+	done2, _ := w.Track(nil, closeFn)
+
+	w.GoAway()
+	if _, ok = w.Track(goAway, closeFn); ok {
+		t.Error("expected websockets to be refused after GoAway")
+	}
+
+	done1()
+	done1()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := w.Wait(ctx); err == nil {
+		t.Error("expected Wait to time out with an open websocket")
+	}
+	w.Close()
+	if closes.Load() != 1 {
+		t.Errorf("expected the open websocket to be closed, got %d closes", closes.Load())
+	}
+
+	done2()
+	if err := w.Wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if goAways.Load() > 1 {
+		t.Errorf("expected one go away, got %d", goAways.Load())
+	}
+
+	var none *Websockets
+	if done, ok := none.Track(goAway, closeFn); !ok || done == nil {
+		t.Error("a nil Websockets should accept every websocket")
+	}
+}
@@ -0,0 +1,150 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed   State = iota // calls go through
+	Open                  // calls are refused until OpenTime is over
+	HalfOpen              // calls go through, the first failure opens again and HalfOpenCalls successes close
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	}
+	return "closed"
+}
+
+type Config struct {
+	Window        time.Duration // error rate window, defaults to 30s
+	MinCalls      int           // calls in the window before the error rate counts, defaults to 20
+	ErrorRate     float64       // failed share of the window calls which opens the breaker, defaults to 0.5
+	SlowCall      time.Duration // calls slower than this count as failed, defaults to 10s
+	OpenTime      time.Duration // defaults to 30s
+	HalfOpenCalls int           // defaults to 5
+}
+
+// Breaker follows the errors and latency of the calls to one upstream node. A nil Breaker is always closed.
+type Breaker struct {
+	cfg Config
+
+	mutex       sync.Mutex
+	state       State
+	openedAt    time.Time
+	windowStart time.Time
+	calls       int
+	failures    int
+	successes   int // half-open only
+}
+
+func New(cfg Config) *Breaker {
+	if cfg.Window == 0 {
+		cfg.Window = time.Second * 30
+	}
+	if cfg.MinCalls == 0 {
+		cfg.MinCalls = 20
+	}
+	if cfg.ErrorRate == 0 {
+		cfg.ErrorRate = 0.5
+	}
+	if cfg.SlowCall == 0 {
+		cfg.SlowCall = time.Second * 10
+	}
+	if cfg.OpenTime == 0 {
+		cfg.OpenTime = time.Second * 30
+	}
+	if cfg.HalfOpenCalls == 0 {
+		cfg.HalfOpenCalls = 5
+	}
+	return &Breaker{cfg: cfg, windowStart: time.Now()}
+}
+
+// Ready reports whether calls may be sent, an open breaker turns half-open when its OpenTime is over
+func (b *Breaker) Ready() bool {
+	if b == nil {
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.halfOpen(time.Now())
+	return b.state != Open
+}
+
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.halfOpen(time.Now())
+	return b.state
+}
+
+// Record counts a finished call, calls cancelled by the caller are not counted
+func (b *Breaker) Record(err error, latency time.Duration) {
+	if b == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	failed := err != nil || latency >= b.cfg.SlowCall
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	b.halfOpen(now)
+	switch b.state {
+	case Open:
+		// a call sent before the breaker opened, or sent anyway as the upstream had no alternative
+	case HalfOpen:
+		if failed {
+			b.open(now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenCalls {
+			b.state = Closed
+			b.resetWindow(now)
+		}
+	case Closed:
+		if now.Sub(b.windowStart) > b.cfg.Window {
+			b.resetWindow(now)
+		}
+		b.calls++
+		if failed {
+			b.failures++
+		}
+		if b.calls >= b.cfg.MinCalls && float64(b.failures) >= float64(b.calls)*b.cfg.ErrorRate {
+			b.open(now)
+		}
+	}
+}
+
+// halfOpen turns an open breaker half-open when its OpenTime is over, calls sent to an upstream without
+// alternative then probe it like the ones let through by Ready
+func (b *Breaker) halfOpen(now time.Time) {
+	if b.state == Open && now.Sub(b.openedAt) >= b.cfg.OpenTime {
+		b.state = HalfOpen
+		b.successes = 0
+	}
+}
+
+func (b *Breaker) open(now time.Time) {
+	b.state = Open
+	b.openedAt = now
+	b.resetWindow(now)
+}
+
+func (b *Breaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.calls = 0
+	b.failures = 0
+}
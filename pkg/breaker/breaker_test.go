@@ -0,0 +1,62 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	failed := errors.New("failed")
+	b := New(Config{MinCalls: 4, ErrorRate: 0.5, SlowCall: time.Second, OpenTime: time.Millisecond * 20, HalfOpenCalls: 2})
+
+	b.Record(nil, 0)
+	b.Record(failed, 0)
+	b.Record(context.Canceled, 0)
+	b.Record(nil, 0)
+	if b.State() != Closed {
+		t.Fatalf("expected closed below the min calls, got %s", b.State())
+	}
+	b.Record(nil, time.Second*2)
+	if b.State() != Open || b.Ready() {
+		t.Fatalf("expected open after 2 of 4 calls failed, got %s", b.State())
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	if !b.Ready() || b.State() != HalfOpen {
+		t.Fatalf("expected half-open after the open time, got %s", b.State())
+	}
+	b.Record(failed, 0)
+	if b.State() != Open {
+		t.Fatalf("expected open after a half-open failure, got %s", b.State())
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	b.Ready()
+	b.Record(nil, 0)
+	b.Record(nil, 0)
+	if b.State() != Closed {
+		t.Fatalf("expected closed after the half-open successes, got %s", b.State())
+	}
+
+	for i := 0; i < 4; i++ {
+		b.Record(failed, 0)
+	}
+	b.Record(nil, 0)
+	if b.State() != Open {
+		t.Fatalf("expected calls recorded while open to be ignored, got %s", b.State())
+	}
+	time.Sleep(time.Millisecond * 30)
+	b.Record(nil, 0)
+	b.Record(nil, 0)
+	if b.State() != Closed {
+		t.Fatalf("expected calls sent without Ready to probe after the open time, got %s", b.State())
+	}
+
+	var nilBreaker *Breaker
+	nilBreaker.Record(failed, 0)
+	if !nilBreaker.Ready() || nilBreaker.State() != Closed {
+		t.Error("expected a nil breaker to be closed")
+	}
+}
@@ -0,0 +1,302 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/upstreamauth"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// StatusMethod is answered by the proxy with the TxStatus of the hash in params[0]
+const StatusMethod = "proxy_getBroadcastStatus"
+
+// Target is a node or public relay which receives every broadcast
+type Target struct {
+	Name string
+	Url  string
+	Auth config.UpstreamAuth
+}
+
+// TxStatus is the submission record of a transaction
+type TxStatus struct {
+	Hash        string            `json:"hash"`
+	Status      string            `json:"status"`
+	Result      json.RawMessage   `json:"result,omitempty"`
+	Error       json.RawMessage   `json:"error,omitempty"`
+	Nodes       map[string]string `json:"nodes"`        // target name => "ok" or the error
+	SubmittedAt int64             `json:"submitted_at"` // unix seconds
+}
+
+type Config struct {
+	ChainName  string
+	HttpClient *http.Client
+	StatusTime time.Duration   // how long outcome records are kept for lookups and retries, defaults to a day
+	Timeout    time.Duration   // per target, defaults to 10s
+	Background *app.Background // records the outcome of the targets answering after the client got its response
+
+	// TxHash keys the status record, calls it can not hash are keyed by the sha256 of their params
+	TxHash func(call *jsonrpc.JsonRpcSingleRequest) (string, error)
+}
+
+// Broadcaster sends signed transactions to all targets in parallel
+type Broadcaster struct {
+	cfg    Config
+	rdb    redis.UniversalClient
+	logger *zap.Logger
+}
+
+func New(rdb redis.UniversalClient, logger *zap.Logger, cfg Config) *Broadcaster {
+	if cfg.HttpClient == nil {
+		cfg.HttpClient = http.DefaultClient
+	}
+	if cfg.StatusTime == 0 {
+		cfg.StatusTime = time.Hour * 24
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second * 10
+	}
+	return &Broadcaster{
+		cfg:    cfg,
+		rdb:    rdb,
+		logger: logger.With(zap.String("chain", cfg.ChainName)),
+	}
+}
+
+func (b *Broadcaster) key(hash string) string {
+	return fmt.Sprintf("broadcast:%s:%s", b.cfg.ChainName, hash)
+}
+
+func (b *Broadcaster) txHash(call *jsonrpc.JsonRpcSingleRequest) string {
+	if b.cfg.TxHash != nil {
+		if hash, err := b.cfg.TxHash(call); err == nil {
+			return hash
+		}
+	}
+	sum := sha256.Sum256(call.Params)
+	return hex.EncodeToString(sum[:])
+}
+
+// pendingTime is how long a pending record lives, a broadcast records its outcome within its timeout. A record
+// left pending by a broadcast which died on the way expires soon, so the transaction can be broadcast again.
+func (b *Broadcaster) pendingTime() time.Duration {
+	return b.cfg.Timeout * 2
+}
+
+// stale reports whether a pending record outlived its broadcast
+func (b *Broadcaster) stale(status *TxStatus, now time.Time) bool {
+	return status.Status == StatusPending && now.After(time.Unix(status.SubmittedAt, 0).Add(b.pendingTime()))
+}
+
+func (b *Broadcaster) save(status *TxStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return
+	}
+	if err = b.rdb.Set(context.Background(), b.key(status.Hash), data, b.cfg.StatusTime).Err(); err != nil {
+		b.logger.Error("failed to save broadcast status", zap.String("hash", status.Hash), zap.Error(err))
+	}
+}
+
+// Status returns nil for unknown hashes
+func (b *Broadcaster) Status(ctx context.Context, hash string) (*TxStatus, error) {
+	data, err := b.rdb.Get(ctx, b.key(hash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status := &TxStatus{}
+	if err = json.Unmarshal(data, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// StatusResponse answers a StatusMethod call
+func (b *Broadcaster) StatusResponse(ctx context.Context, call *jsonrpc.JsonRpcSingleRequest) ([]byte, error) {
+	var params []string
+	if err := json.Unmarshal(call.Params, &params); err != nil || len(params) == 0 {
+		return json.Marshal(jsonrpc.NewInvalidParamsError(call.ID, "expected the transaction hash as first param"))
+	}
+	status, err := b.Status(ctx, params[0])
+	if err != nil {
+		return nil, err
+	}
+	result, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonrpc.JsonRpcResponse{ID: call.ID, JsonRpcVersion: "2.0", Result: result})
+}
+
+// recorded waits for a broadcast of the same transaction in flight and returns its response, nil when
+// there is nothing to reuse and the transaction has to be broadcast again
+func (b *Broadcaster) recorded(ctx context.Context, call *jsonrpc.JsonRpcSingleRequest, hash string) ([]byte, error) {
+	for {
+		status, err := b.Status(ctx, hash)
+		if err != nil || status == nil {
+			return nil, err
+		}
+		switch {
+		case status.Status == StatusSuccess:
+			return json.Marshal(jsonrpc.JsonRpcResponse{ID: call.ID, JsonRpcVersion: "2.0", Result: status.Result})
+		case status.Status == StatusFailed, b.stale(status, time.Now()):
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Millisecond * 200):
+		}
+	}
+}
+
+type outcome struct {
+	target Target
+	raw    []byte
+	resp   *jsonrpc.JsonRpcResponse
+	err    error
+}
+
+func (b *Broadcaster) send(ctx context.Context, target Target, body []byte) outcome {
+	o := outcome{target: target}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Url, bytes.NewReader(body))
+	if err != nil {
+		o.err = err
+		return o
+	}
+	upstreamauth.Apply(req.Header, target.Auth)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := b.cfg.HttpClient.Do(req)
+	if err != nil {
+		o.err = upstreamauth.RedactError(err)
+		return o
+	}
+	defer res.Body.Close()
+	if o.raw, o.err = io.ReadAll(res.Body); o.err != nil {
+		return o
+	}
+	if res.StatusCode != http.StatusOK {
+		o.err = fmt.Errorf("unexpected status %s", res.Status)
+		return o
+	}
+	o.resp = &jsonrpc.JsonRpcResponse{}
+	o.err = json.Unmarshal(o.raw, o.resp)
+	return o
+}
+
+// Broadcast sends call to all targets in parallel and returns the first successful response, or the first
+// failure when no target accepts the transaction. A retry of a transaction which is in flight or already
+// accepted gets the recorded response without a new broadcast.
+func (b *Broadcaster) Broadcast(ctx context.Context, call *jsonrpc.JsonRpcSingleRequest, targets []Target) ([]byte, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("no broadcast target")
+	}
+
+	status := &TxStatus{
+		Hash:        b.txHash(call),
+		Status:      StatusPending,
+		Nodes:       map[string]string{},
+		SubmittedAt: time.Now().Unix(),
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	created, err := b.rdb.SetNX(ctx, b.key(status.Hash), data, b.pendingTime()).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		if resp, err := b.recorded(ctx, call, status.Hash); resp != nil || err != nil {
+			return resp, err
+		}
+	}
+
+	body, err := json.Marshal(call)
+	if err != nil {
+		return nil, err
+	}
+
+	// slower targets keep going after the client got its response, their outcome is recorded
+	sendCtx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+	outcomes := make(chan outcome, len(targets))
+	first := make(chan outcome, 1)
+	started := b.cfg.Background.Go(func(context.Context) {
+		defer cancel()
+		var failure *outcome
+		answered := false
+		for range targets {
+			o := <-outcomes
+			switch {
+			case o.err != nil:
+				status.Nodes[o.target.Name] = o.err.Error()
+			case len(o.resp.Error) > 0:
+				status.Nodes[o.target.Name] = string(o.resp.Error)
+			default:
+				status.Nodes[o.target.Name] = "ok"
+				if !answered {
+					answered = true
+					status.Status = StatusSuccess
+					status.Result = o.resp.Result
+					b.save(status)
+					first <- o
+				}
+				continue
+			}
+			if failure == nil || (failure.err != nil && o.err == nil) {
+				failure = &o
+			}
+		}
+		if !answered {
+			status.Status = StatusFailed
+			if failure.resp != nil {
+				status.Error = failure.resp.Error
+			}
+			first <- *failure
+		}
+		b.save(status)
+		b.logger.Debug("broadcast finished", zap.String("hash", status.Hash), zap.Any("nodes", status.Nodes))
+	})
+	if !started {
+		cancel()
+		return nil, errors.New("shutting down")
+	}
+	for _, target := range targets {
+		go func(target Target) {
+			outcomes <- b.send(sendCtx, target, body)
+		}(target)
+	}
+
+	select {
+	case o := <-first:
+		if o.err != nil {
+			return nil, o.err
+		}
+		return o.raw, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
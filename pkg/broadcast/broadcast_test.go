@@ -0,0 +1,25 @@
+package broadcast
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestStale(t *testing.T) {
+	b := New(nil, zap.NewNop(), Config{Timeout: time.Second * 10})
+	now := time.Now()
+	status := &TxStatus{Status: StatusPending, SubmittedAt: now.Add(-time.Second * 5).Unix()}
+	if b.stale(status, now) {
+		t.Error("a broadcast within its timeout is not stale")
+	}
+	status.SubmittedAt = now.Add(-time.Minute).Unix()
+	if !b.stale(status, now) {
+		t.Error("a pending record older than the broadcast timeout should be stale")
+	}
+	status.Status = StatusSuccess
+	if b.stale(status, now) {
+		t.Error("an outcome record is never stale")
+	}
+}
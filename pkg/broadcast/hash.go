@@ -0,0 +1,50 @@
+package broadcast
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EvmTxHash is the keccak256 of the signed transaction of eth_sendRawTransaction
+func EvmTxHash(call *jsonrpc.JsonRpcSingleRequest) (string, error) {
+	var params []string
+	if err := json.Unmarshal(call.Params, &params); err != nil || len(params) == 0 {
+		return "", fmt.Errorf("invalid %s params", call.Method)
+	}
+	raw, err := hexutil.Decode(params[0])
+	if err != nil {
+		return "", err
+	}
+	return crypto.Keccak256Hash(raw).Hex(), nil
+}
+
+// TendermintTxHash is the sha256 of the base64 tx of broadcast_tx_*, upper case hex as tendermint returns it
+func TendermintTxHash(call *jsonrpc.JsonRpcSingleRequest) (string, error) {
+	var tx string
+	var named struct {
+		Tx string `json:"tx"`
+	}
+	var positional []string
+	if err := json.Unmarshal(call.Params, &named); err == nil && named.Tx != "" {
+		tx = named.Tx
+	} else if err := json.Unmarshal(call.Params, &positional); err == nil && len(positional) > 0 {
+		tx = positional[0]
+	} else {
+		return "", fmt.Errorf("invalid %s params", call.Method)
+	}
+	raw, err := base64.StdEncoding.DecodeString(tx)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:])), nil
+}
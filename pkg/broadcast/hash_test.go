@@ -0,0 +1,42 @@
+package broadcast
+
+import (
+	"encoding/json"
+	"testing"
+
+	"starnet/chain-api/pkg/jsonrpc"
+)
+
+func TestEvmTxHash(t *testing.T) {
+	call := &jsonrpc.JsonRpcSingleRequest{
+		Method: "eth_sendRawTransaction",
+		Params: json.RawMessage(`["0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"]`),
+	}
+	hash, err := EvmTxHash(call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788" {
+		t.Errorf("unexpected hash %s", hash)
+	}
+
+	if _, err := EvmTxHash(&jsonrpc.JsonRpcSingleRequest{Params: json.RawMessage(`["0xzz"]`)}); err == nil {
+		t.Error("expected error for invalid hex")
+	}
+}
+
+func TestTendermintTxHash(t *testing.T) {
+	for _, params := range []string{`{"tx":"aGVsbG8="}`, `["aGVsbG8="]`} {
+		hash, err := TendermintTxHash(&jsonrpc.JsonRpcSingleRequest{Method: "broadcast_tx_sync", Params: json.RawMessage(params)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824" {
+			t.Errorf("%s: unexpected hash %s", params, hash)
+		}
+	}
+
+	if _, err := TendermintTxHash(&jsonrpc.JsonRpcSingleRequest{Params: json.RawMessage(`{}`)}); err == nil {
+		t.Error("expected error for missing tx")
+	}
+}
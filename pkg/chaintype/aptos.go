@@ -0,0 +1,29 @@
+package chaintype
+
+import (
+	"starnet/chain-api/config"
+)
+
+func init() {
+	Register(Aptos{})
+}
+
+// Aptos is probed by the ledger version of the REST api
+type Aptos struct {
+	Base
+}
+
+func (Aptos) Name() string {
+	return "aptos"
+}
+
+func (Aptos) SetDefaults(cfg *config.ChainConfig) {
+	cfg.BlockNumberResultExpression = ".ledger_version"
+	if cfg.HttpHealth.Path == "" {
+		cfg.HttpHealth.Method = "GET"
+		cfg.HttpHealth.Path = "/v1"
+	}
+	if cfg.WritePaths == nil {
+		cfg.WritePaths = []string{"/v1/transactions", "/v1/transactions/batch"}
+	}
+}
@@ -0,0 +1,67 @@
+package chaintype
+
+import (
+	"net/http"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/utils"
+
+	"github.com/itchyny/gojq"
+	"github.com/samber/lo"
+)
+
+// Base implements a REST chain type which is probed by its health checks and has no websocket,
+// chain types embed it and override what differs.
+type Base struct{}
+
+func (Base) SetDefaults(cfg *config.ChainConfig) {}
+
+func (Base) ProbeHttp(c *Chain, node *config.RpcNode) (uint64, error) {
+	return getBlockNumberFromHealthCheck(c, node, node.Http, c.Config.HttpHealth, c.httpQuery)
+}
+
+func (Base) ProbeWs(c *Chain, node *config.RpcNode) (uint64, error) {
+	return 0, ErrProbeUnsupported
+}
+
+func (Base) ProbeExtraWrite(c *Chain, node *config.RpcNode) (uint64, error) {
+	return getBlockNumberFromHealthCheck(c, node, node.ExtraWrite, c.Config.ExtraWriteHealth, c.extraWriteQuery)
+}
+
+func (Base) ExtractBlockNumber(query *gojq.Query, body []byte) (uint64, error) {
+	result, err := utils.JqQueryFirst(body, query)
+	if err != nil {
+		return 0, err
+	}
+	return utils.ToUint64(result)
+}
+
+func (Base) IsJsonRpc() bool {
+	return false
+}
+
+func (Base) ValidateCall(c *Chain, call *jsonrpc.JsonRpcSingleRequest) *jsonrpc.JsonRpcErr {
+	if call.Method == "" {
+		return jsonrpc.ParseError
+	}
+	if utils.In(call.Method, c.Config.BlackMethods) {
+		return jsonrpc.NewUnsupportedMethodError(call.ID)
+	}
+	return nil
+}
+
+// IsWriteRequest matches non GET requests against the write_paths of the chain
+func (Base) IsWriteRequest(c *Chain, r *http.Request, path string) bool {
+	return r.Method != http.MethodGet && r.Method != http.MethodHead && utils.MatchAnyPath(c.Config.WritePaths, path)
+}
+
+func (Base) TxHash(call *jsonrpc.JsonRpcSingleRequest) (string, error) {
+	return "", ErrTxHashUnsupported
+}
+
+// mergeBlackMethods returns the methods a chain type always blocks with the configured black_methods, configured
+// methods are blocked in addition to them, never instead of them
+func mergeBlackMethods(typeMethods, configured []string) []string {
+	return lo.Uniq(append(append([]string{}, typeMethods...), configured...))
+}
@@ -0,0 +1,160 @@
+package chaintype
+
+import (
+	"fmt"
+	"net/http"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/httpclient"
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"github.com/gorilla/websocket"
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+)
+
+// ChainType is a family of chains served by the RpcHandler, e.g. evm or svm.
+// Implementations register themselves in init and usually embed Base.
+type ChainType interface {
+	// Name is the chain_type value of the rpc config
+	Name() string
+	// SetDefaults fills the chain config fields which are not set in the rpc config
+	SetDefaults(cfg *config.ChainConfig)
+	// ProbeHttp returns the block number of the node http endpoint
+	ProbeHttp(c *Chain, node *config.RpcNode) (uint64, error)
+	// ProbeWs returns the block number of the node websocket endpoint
+	ProbeWs(c *Chain, node *config.RpcNode) (uint64, error)
+	// ProbeExtraWrite returns the block number of the node extra write endpoint
+	ProbeExtraWrite(c *Chain, node *config.RpcNode) (uint64, error)
+	// ExtractBlockNumber reads the block number from a health check response
+	ExtractBlockNumber(query *gojq.Query, body []byte) (uint64, error)
+	// IsJsonRpc reports whether http requests are JSON-RPC calls which can be checked by ValidateCall
+	IsJsonRpc() bool
+	// ValidateCall checks a single JSON-RPC call before it is forwarded
+	ValidateCall(c *Chain, call *jsonrpc.JsonRpcSingleRequest) *jsonrpc.JsonRpcErr
+	// IsWriteRequest reports whether the request must be served by the write pool
+	IsWriteRequest(c *Chain, r *http.Request, path string) bool
+	// TxHash returns the hash of the transaction submitted by a broadcast call
+	TxHash(call *jsonrpc.JsonRpcSingleRequest) (string, error)
+}
+
+var (
+	ErrProbeUnsupported  = errors.New("probe is not supported by the chain type")
+	ErrTxHashUnsupported = errors.New("tx hash is not supported by the chain type")
+)
+
+var chainTypes = make(map[string]ChainType)
+
+// Register makes a chain type available to the rpc config, it panics on duplicated names
+func Register(t ChainType) {
+	if _, ok := chainTypes[t.Name()]; ok {
+		panic(fmt.Sprintf("chain type %s is already registered", t.Name()))
+	}
+	chainTypes[t.Name()] = t
+}
+
+// Get returns the registered chain type
+func Get(name string) (ChainType, error) {
+	t, ok := chainTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported chain type: %s", name)
+	}
+	return t, nil
+}
+
+// Configure applies the chain type defaults to every chain of the rpc config
+func Configure(rpcConfig *config.RpcConfig) error {
+	for i := range rpcConfig.Chains {
+		cfg := &rpcConfig.Chains[i]
+		t, err := Get(cfg.ChainType)
+		if err != nil {
+			return err
+		}
+		t.SetDefaults(cfg)
+		setHealthCheckDefaults(&cfg.HttpHealth, cfg.BlockNumberMethod, cfg.BlockNumberResultExpression)
+		setHealthCheckDefaults(&cfg.ExtraWriteHealth, "", cfg.BlockNumberResultExpression)
+	}
+	return nil
+}
+
+// setHealthCheckDefaults fills a json-rpc block number call when the chain has a block number method
+func setHealthCheckDefaults(c *config.HealthCheck, blockNumberMethod, resultExpression string) {
+	if c.Method == "" {
+		c.Method = "GET"
+		if blockNumberMethod != "" && c.Path == "" && c.Body == "" {
+			c.Method = "POST"
+			c.Body = fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":1}`, blockNumberMethod)
+		}
+	}
+	if c.ResultExpression == "" {
+		c.ResultExpression = resultExpression
+	}
+}
+
+// Chain is a configured chain of a chain type
+type Chain struct {
+	Config     *config.ChainConfig
+	Type       ChainType
+	HttpClient *http.Client      // of the calls to the node http endpoints, health probes included
+	WsDialer   *websocket.Dialer // of the node websockets, with the tls, proxy and timeouts of HttpClient
+
+	blockNumberQuery *gojq.Query
+	httpQuery        *gojq.Query
+	extraWriteQuery  *gojq.Query
+}
+
+func New(cfg *config.ChainConfig) (*Chain, error) {
+	t, err := Get(cfg.ChainType)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BlockNumberResultExtractor != "jq" {
+		return nil, fmt.Errorf("unsupported block number result extractor: %s, only jq is supported", cfg.BlockNumberResultExtractor)
+	}
+
+	c := &Chain{Config: cfg, Type: t}
+	if c.HttpClient, err = httpclient.New(cfg.HttpClient); err != nil {
+		return nil, err
+	}
+	if c.WsDialer, err = httpclient.NewWsDialer(cfg.HttpClient); err != nil {
+		return nil, err
+	}
+	if c.blockNumberQuery, err = gojq.Parse(cfg.BlockNumberResultExpression); err != nil {
+		return nil, errors.Wrap(err, "failed to parse block number result expression")
+	}
+	if c.httpQuery, err = gojq.Parse(cfg.HttpHealth.ResultExpression); err != nil {
+		return nil, errors.Wrap(err, "failed to parse http health result expression")
+	}
+	if c.extraWriteQuery, err = gojq.Parse(cfg.ExtraWriteHealth.ResultExpression); err != nil {
+		return nil, errors.Wrap(err, "failed to parse extra write health result expression")
+	}
+	return c, nil
+}
+
+func (c *Chain) ProbeHttp(node *config.RpcNode) (uint64, error) {
+	return c.Type.ProbeHttp(c, node)
+}
+
+func (c *Chain) ProbeWs(node *config.RpcNode) (uint64, error) {
+	return c.Type.ProbeWs(c, node)
+}
+
+func (c *Chain) ProbeExtraWrite(node *config.RpcNode) (uint64, error) {
+	return c.Type.ProbeExtraWrite(c, node)
+}
+
+func (c *Chain) IsJsonRpc() bool {
+	return c.Type.IsJsonRpc()
+}
+
+func (c *Chain) ValidateCall(call *jsonrpc.JsonRpcSingleRequest) *jsonrpc.JsonRpcErr {
+	return c.Type.ValidateCall(c, call)
+}
+
+func (c *Chain) IsWriteRequest(r *http.Request, path string) bool {
+	return c.Type.IsWriteRequest(c, r, path)
+}
+
+func (c *Chain) TxHash(call *jsonrpc.JsonRpcSingleRequest) (string, error) {
+	return c.Type.TxHash(call)
+}
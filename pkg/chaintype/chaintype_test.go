@@ -0,0 +1,197 @@
+package chaintype
+
+import (
+	"testing"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/jsonrpc"
+)
+
+func TestConfigure(t *testing.T) {
+	rpcConfig, err := config.LoadRPCConfig(`
+apikey = "key"
+
+[eth]
+
+[aptos]
+chain_type = "aptos"
+
+[tron]
+chain_type = "tron"
+
+[[tron.nodes]]
+name = "node1"
+http = "https://tron/jsonrpc"
+extra_write = "https://tron/wallet"
+pool = "write"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Configure(rpcConfig); err != nil {
+		t.Fatal(err)
+	}
+	chains := make(map[string]config.ChainConfig)
+	for _, chain := range rpcConfig.Chains {
+		chains[chain.ChainName] = chain
+		if _, err := New(&chain); err != nil {
+			t.Errorf("New(%s) = %v", chain.ChainName, err)
+		}
+	}
+
+	eth := chains["eth"]
+	if eth.ChainType != "evm" || eth.BlockNumberMethod != "eth_blockNumber" || eth.HttpHealth.Method != "POST" {
+		t.Errorf("unexpected evm defaults: %+v", eth)
+	}
+
+	aptos := chains["aptos"]
+	if aptos.HttpHealth.Method != "GET" || aptos.HttpHealth.Path != "/v1" || aptos.HttpHealth.ResultExpression != ".ledger_version" {
+		t.Errorf("unexpected aptos http health check: %+v", aptos.HttpHealth)
+	}
+
+	tron := chains["tron"]
+	if tron.MaxBehindBlocks != 40 {
+		t.Errorf("unexpected tron max behind blocks: %d", tron.MaxBehindBlocks)
+	}
+	if tron.HttpHealth.Method != "POST" || tron.HttpHealth.Body == "" || tron.HttpHealth.ResultExpression != ".result" {
+		t.Errorf("unexpected tron http health check: %+v", tron.HttpHealth)
+	}
+	if tron.ExtraWriteHealth.Path != "/getnowblock" || tron.ExtraWriteHealth.ResultExpression != ".block_header.raw_data.number" {
+		t.Errorf("unexpected tron extra write health check: %+v", tron.ExtraWriteHealth)
+	}
+	if len(tron.Nodes) != 1 || tron.Nodes[0].Pool != config.NodePoolWrite {
+		t.Errorf("unexpected tron nodes: %+v", tron.Nodes)
+	}
+}
+
+func TestConfigureUnsupportedChainType(t *testing.T) {
+	rpcConfig, err := config.LoadRPCConfig(`
+apikey = "key"
+
+[lisk]
+chain_type = "lisk"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Configure(rpcConfig); err == nil {
+		t.Fatal("expected unsupported chain type error")
+	}
+}
+
+func TestConfigureUtxo(t *testing.T) {
+	rpcConfig, err := config.LoadRPCConfig(`
+apikey = "key"
+
+[bitcoin]
+chain_type = "utxo"
+
+[[bitcoin.nodes]]
+name = "node1"
+http = "http://127.0.0.1:8332"
+username = "rpcuser"
+password = "rpcpassword"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Configure(rpcConfig); err != nil {
+		t.Fatal(err)
+	}
+	bitcoin := rpcConfig.Chains[0]
+	if bitcoin.HttpHealth.Method != "POST" || bitcoin.HttpHealth.Body != `{"jsonrpc":"1.0","id":1,"method":"getblockcount","params":[]}` {
+		t.Errorf("unexpected utxo http health check: %+v", bitcoin.HttpHealth)
+	}
+
+	chain, err := New(&bitcoin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"dumpprivkey", "stop", "importprivkey"} {
+		if err := chain.ValidateCall(&jsonrpc.JsonRpcSingleRequest{Method: method}); err == nil {
+			t.Errorf("expected %s to be blocked", method)
+		}
+	}
+	if err := chain.ValidateCall(&jsonrpc.JsonRpcSingleRequest{Method: "getblockcount"}); err != nil {
+		t.Errorf("unexpected error for getblockcount: %v", err)
+	}
+
+	header := NodeWsHeader(&bitcoin.Nodes[0])
+	if header.Get("Authorization") != "Basic cnBjdXNlcjpycGNwYXNzd29yZA==" {
+		t.Errorf("unexpected authorization header: %s", header.Get("Authorization"))
+	}
+}
+
+func TestConfigureUtxoCustomBlackMethods(t *testing.T) {
+	rpcConfig, err := config.LoadRPCConfig(`
+apikey = "key"
+
+[bitcoin]
+chain_type = "utxo"
+black_methods = ["getblock"]
+
+[[bitcoin.nodes]]
+name = "node1"
+http = "http://127.0.0.1:8332"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Configure(rpcConfig); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := New(&rpcConfig.Chains[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"getblock", "dumpprivkey", "stop"} {
+		if err := chain.ValidateCall(&jsonrpc.JsonRpcSingleRequest{Method: method}); err == nil {
+			t.Errorf("expected %s to be blocked", method)
+		}
+	}
+}
+
+func TestConfigureSubstrate(t *testing.T) {
+	rpcConfig, err := config.LoadRPCConfig(`
+apikey = "key"
+
+[polkadot]
+chain_type = "substrate"
+black_methods = ["state_getPairs"]
+
+[[polkadot.nodes]]
+name = "node1"
+http = "http://127.0.0.1:9933"
+ws = "ws://127.0.0.1:9944"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Configure(rpcConfig); err != nil {
+		t.Fatal(err)
+	}
+	polkadot := rpcConfig.Chains[0]
+	if polkadot.BlockNumberMethod != "chain_getHeader" || polkadot.BlockNumberResultExpression != ".result.number" {
+		t.Errorf("unexpected substrate block number config: %s %s", polkadot.BlockNumberMethod, polkadot.BlockNumberResultExpression)
+	}
+
+	chain, err := New(&polkadot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockNumber, err := chain.Type.ExtractBlockNumber(chain.httpQuery, []byte(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x1a2b","parentHash":"0x00"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockNumber != 0x1a2b {
+		t.Errorf("expected block number %d, got %d", 0x1a2b, blockNumber)
+	}
+	for _, method := range []string{"author_rotateKeys", "state_getPairs"} {
+		if err := chain.ValidateCall(&jsonrpc.JsonRpcSingleRequest{Method: method}); err == nil {
+			t.Errorf("expected %s to be blocked", method)
+		}
+	}
+	if err := chain.ValidateCall(&jsonrpc.JsonRpcSingleRequest{Method: "chain_subscribeNewHeads"}); err != nil {
+		t.Errorf("unexpected error for chain_subscribeNewHeads: %v", err)
+	}
+}
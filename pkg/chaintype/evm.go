@@ -0,0 +1,44 @@
+package chaintype
+
+import (
+	"fmt"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/broadcast"
+	"starnet/chain-api/pkg/jsonrpc"
+)
+
+func init() {
+	Register(Evm{})
+}
+
+// Evm is an ethereum compatible JSON-RPC chain
+type Evm struct {
+	Base
+}
+
+func (Evm) Name() string {
+	return "evm"
+}
+
+func (Evm) SetDefaults(cfg *config.ChainConfig) {
+	if cfg.BlockNumberMethod == "" {
+		cfg.BlockNumberMethod = "eth_blockNumber"
+	}
+	if cfg.BroadcastMethods == nil {
+		cfg.BroadcastMethods = []string{"eth_sendRawTransaction"}
+	}
+}
+
+func (Evm) ProbeWs(c *Chain, node *config.RpcNode) (uint64, error) {
+	content := fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":1}`, c.Config.BlockNumberMethod)
+	return getBlockNumberFromEvmWs(c.WsDialer, node.Ws, NodeWsHeader(node), content, c.blockNumberQuery)
+}
+
+func (Evm) IsJsonRpc() bool {
+	return true
+}
+
+func (Evm) TxHash(call *jsonrpc.JsonRpcSingleRequest) (string, error) {
+	return broadcast.EvmTxHash(call)
+}
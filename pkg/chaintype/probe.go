@@ -0,0 +1,154 @@
+package chaintype
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/upstreamauth"
+	"starnet/chain-api/pkg/utils"
+
+	"github.com/gorilla/websocket"
+	"github.com/itchyny/gojq"
+)
+
+// probeTimeout bounds a health check request, the check runs every minute
+const probeTimeout = time.Second * 10
+
+// getBlockNumberFromHealthCheck sends the health check request to an endpoint of a node
+func getBlockNumberFromHealthCheck(c *Chain, node *config.RpcNode, baseUrl string, check config.HealthCheck, jqQuery *gojq.Query) (uint64, error) {
+	url := baseUrl
+	if check.Path != "" {
+		url = strings.TrimRight(baseUrl, "/") + "/" + strings.TrimLeft(check.Path, "/")
+	}
+	var body io.Reader
+	if check.Body != "" {
+		body = strings.NewReader(check.Body)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, check.Method, url, body)
+	if err != nil {
+		return 0, err
+	}
+	if check.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	SetNodeAuth(req.Header, node)
+	respBody, err := doHttp(c.HttpClient, req)
+	if err != nil {
+		return 0, err
+	}
+	return c.Type.ExtractBlockNumber(jqQuery, respBody)
+}
+
+// SetNodeAuth adds the upstream credentials of the node to the request header
+func SetNodeAuth(header http.Header, node *config.RpcNode) {
+	upstreamauth.Apply(header, node.Auth)
+}
+
+// NodeWsHeader returns the header used to dial the node websocket endpoint
+func NodeWsHeader(node *config.RpcNode) http.Header {
+	header := http.Header{}
+	SetNodeAuth(header, node)
+	return header
+}
+
+func doHttp(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, upstreamauth.RedactError(err)
+	}
+	if resp == nil || resp.Body == nil {
+		return nil, fmt.Errorf("response is nil")
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+func getBlockNumberFromEvmWs(dialer *websocket.Dialer, url string, requestHeader http.Header, content string, jqQuery *gojq.Query) (uint64, error) {
+	upstream, err := utils.DialWs(dialer, url, requestHeader)
+	if err != nil {
+		return 0, err
+	}
+	defer upstream.Close()
+	if err = upstream.WriteMessage(websocket.TextMessage, []byte(content)); err != nil {
+		return 0, err
+	}
+
+	for {
+		messageType, message, err := upstream.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		return wsBlockNumber(message, jqQuery)
+	}
+}
+
+// getBlockNumberFromWsSubscription subscribes to new blocks and returns the number of the first notification
+func getBlockNumberFromWsSubscription(dialer *websocket.Dialer, url string, requestHeader http.Header, subscribeMethod, unsubscribeMethod string, jqQuery *gojq.Query) (uint64, error) {
+	upstream, err := utils.DialWs(dialer, url, requestHeader)
+	if err != nil {
+		return 0, err
+	}
+	var subscriptionID json.RawMessage
+	defer func() {
+		if subscriptionID != nil {
+			unsubscribeMessage := fmt.Sprintf(`{"jsonrpc": "2.0","id": 2,"method": "%s", "params": [%s]}`, unsubscribeMethod, subscriptionID)
+			upstream.WriteMessage(websocket.TextMessage, []byte(unsubscribeMessage))
+		}
+		upstream.Close()
+	}()
+
+	subscribeMessage := fmt.Sprintf(`{"jsonrpc": "2.0","id": 1,"method": "%s"}`, subscribeMethod)
+	if err = upstream.WriteMessage(websocket.TextMessage, []byte(subscribeMessage)); err != nil {
+		return 0, err
+	}
+	_, message, err := upstream.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+	var subscription struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(message, &subscription); err != nil {
+		return 0, err
+	}
+	if subscription.Result == nil {
+		return 0, fmt.Errorf("failed to subscribe: %s", subscription.Error)
+	}
+	subscriptionID = subscription.Result
+
+	for {
+		messageType, message, err := upstream.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		return wsBlockNumber(message, jqQuery)
+	}
+}
+
+func wsBlockNumber(message []byte, jqQuery *gojq.Query) (uint64, error) {
+	blockNumber, err := Base{}.ExtractBlockNumber(jqQuery, message)
+	if err != nil {
+		return 0, err
+	}
+	if blockNumber == 0 {
+		return 0, fmt.Errorf("main WS blockNumber is 0")
+	}
+	return blockNumber, nil
+}
@@ -0,0 +1,20 @@
+package chaintype
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/itchyny/gojq"
+)
+
+func TestGetBlockNumberFromWs(t *testing.T) {
+	jqQuery, err := gojq.Parse(".result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := getBlockNumberFromEvmWs(nil, "wss://ethereum-rpc.publicnode.com", nil, `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`, jqQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("got", got)
+}
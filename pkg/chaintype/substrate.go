@@ -0,0 +1,59 @@
+package chaintype
+
+import (
+	"starnet/chain-api/config"
+)
+
+func init() {
+	Register(Substrate{})
+}
+
+// substrateBlackMethods change the node keys, peers or logging and are marked unsafe by substrate
+var substrateBlackMethods = []string{
+	"author_rotateKeys",
+	"author_insertKey",
+	"author_hasKey",
+	"author_hasSessionKeys",
+	"author_removeExtrinsic",
+	"system_addReservedPeer",
+	"system_removeReservedPeer",
+	"system_addLogFilter",
+	"system_resetLogFilter",
+	"system_peers",
+	"system_unstable_networkState",
+	"offchain_localStorageGet",
+	"offchain_localStorageSet",
+}
+
+var substrateNewHeadQuery = mustParseJq(".params.result.number")
+
+// Substrate is a polkadot sdk JSON-RPC chain, block numbers are hex strings in the header
+type Substrate struct {
+	Base
+}
+
+func (Substrate) Name() string {
+	return "substrate"
+}
+
+func (Substrate) SetDefaults(cfg *config.ChainConfig) {
+	if cfg.BlockNumberMethod == "" {
+		cfg.BlockNumberMethod = "chain_getHeader"
+	}
+	if cfg.BlockNumberResultExpression == config.DefaultBlockNumberResultExpression {
+		cfg.BlockNumberResultExpression = ".result.number"
+	}
+	cfg.BlackMethods = mergeBlackMethods(substrateBlackMethods, cfg.BlackMethods)
+	if cfg.BroadcastMethods == nil {
+		cfg.BroadcastMethods = []string{"author_submitExtrinsic"}
+	}
+}
+
+// ProbeWs reads the number of the first chain_subscribeNewHeads notification
+func (Substrate) ProbeWs(c *Chain, node *config.RpcNode) (uint64, error) {
+	return getBlockNumberFromWsSubscription(c.WsDialer, node.Ws, NodeWsHeader(node), "chain_subscribeNewHeads", "chain_unsubscribeNewHeads", substrateNewHeadQuery)
+}
+
+func (Substrate) IsJsonRpc() bool {
+	return true
+}
@@ -0,0 +1,56 @@
+package chaintype
+
+import (
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"github.com/itchyny/gojq"
+)
+
+func init() {
+	Register(Svm{})
+}
+
+var svmSlotQuery = mustParseJq(".params.result.slot")
+
+// Svm is a solana compatible JSON-RPC chain
+type Svm struct {
+	Base
+}
+
+func (Svm) Name() string {
+	return "svm"
+}
+
+func (Svm) SetDefaults(cfg *config.ChainConfig) {
+	if cfg.BlockNumberMethod == "" {
+		cfg.BlockNumberMethod = "getBlockHeight"
+	}
+	if cfg.BroadcastMethods == nil {
+		cfg.BroadcastMethods = []string{"sendTransaction"}
+	}
+}
+
+// ProbeWs reads the slot of a slotSubscribe notification, which is never behind the block height
+func (Svm) ProbeWs(c *Chain, node *config.RpcNode) (uint64, error) {
+	return getBlockNumberFromWsSubscription(c.WsDialer, node.Ws, NodeWsHeader(node), "slotSubscribe", "slotUnsubscribe", svmSlotQuery)
+}
+
+func (Svm) IsJsonRpc() bool {
+	return true
+}
+
+func (t Svm) ValidateCall(c *Chain, call *jsonrpc.JsonRpcSingleRequest) *jsonrpc.JsonRpcErr {
+	if err := t.Base.ValidateCall(c, call); err != nil {
+		return err
+	}
+	return jsonrpc.ValidateSolanaRequest(call)
+}
+
+func mustParseJq(expression string) *gojq.Query {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		panic(err)
+	}
+	return query
+}
@@ -0,0 +1,35 @@
+package chaintype
+
+import (
+	"starnet/chain-api/config"
+)
+
+func init() {
+	Register(Tron{})
+}
+
+// Tron serves the eth compatible JSON-RPC on http and the wallet api on extra_write
+type Tron struct {
+	Base
+}
+
+func (Tron) Name() string {
+	return "tron"
+}
+
+func (Tron) SetDefaults(cfg *config.ChainConfig) {
+	cfg.BlockNumberMethod = "eth_blockNumber"
+	if cfg.MaxBehindBlocks == config.DefaultMaxBehindBlocks {
+		cfg.MaxBehindBlocks = 40 // 120 seconds
+	}
+	if cfg.ExtraWriteHealth.Path == "" {
+		cfg.ExtraWriteHealth = config.HealthCheck{
+			Method:           "POST",
+			Path:             "/getnowblock",
+			ResultExpression: ".block_header.raw_data.number",
+		}
+	}
+	if cfg.WritePaths == nil {
+		cfg.WritePaths = []string{"*/broadcasttransaction", "*/broadcasthex"}
+	}
+}
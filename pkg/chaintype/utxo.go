@@ -0,0 +1,78 @@
+package chaintype
+
+import (
+	"starnet/chain-api/config"
+)
+
+func init() {
+	Register(Utxo{})
+}
+
+// utxoBlackMethods control the node or its wallet and must not be reachable by clients
+var utxoBlackMethods = []string{
+	"stop",
+	"dumpprivkey",
+	"dumpwallet",
+	"importprivkey",
+	"importwallet",
+	"importaddress",
+	"importpubkey",
+	"importmulti",
+	"importdescriptors",
+	"encryptwallet",
+	"walletpassphrase",
+	"walletpassphrasechange",
+	"walletlock",
+	"backupwallet",
+	"createwallet",
+	"loadwallet",
+	"unloadwallet",
+	"sethdseed",
+	"keypoolrefill",
+	"sendtoaddress",
+	"sendmany",
+	"sendfrom",
+	"signrawtransactionwithwallet",
+	"signmessage",
+	"addnode",
+	"disconnectnode",
+	"setban",
+	"clearbanned",
+	"setnetworkactive",
+	"generate",
+	"generatetoaddress",
+	"generateblock",
+	"pruneblockchain",
+	"savemempool",
+	"invalidateblock",
+	"reconsiderblock",
+	"preciousblock",
+}
+
+// Utxo is a bitcoin family JSON-RPC chain, e.g. bitcoin, litecoin or dogecoin
+type Utxo struct {
+	Base
+}
+
+func (Utxo) Name() string {
+	return "utxo"
+}
+
+func (Utxo) SetDefaults(cfg *config.ChainConfig) {
+	if cfg.BlockNumberMethod == "" {
+		cfg.BlockNumberMethod = "getblockcount"
+	}
+	// older nodes only understand json-rpc 1.0 requests
+	if cfg.HttpHealth.Method == "" && cfg.HttpHealth.Body == "" {
+		cfg.HttpHealth.Method = "POST"
+		cfg.HttpHealth.Body = `{"jsonrpc":"1.0","id":1,"method":"` + cfg.BlockNumberMethod + `","params":[]}`
+	}
+	cfg.BlackMethods = mergeBlackMethods(utxoBlackMethods, cfg.BlackMethods)
+	if cfg.BroadcastMethods == nil {
+		cfg.BroadcastMethods = []string{"sendrawtransaction"}
+	}
+}
+
+func (Utxo) IsJsonRpc() bool {
+	return true
+}
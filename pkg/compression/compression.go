@@ -0,0 +1,156 @@
+// Package compression negotiates the content encoding of responses with the client and compresses large cache
+// entries
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Gzip   = "gzip"
+	Zstd   = "zstd"
+	Brotli = "br"
+
+	// MinSize responses smaller than this are sent as they are, compressing them does not pay off
+	MinSize = 1024
+
+	// CacheMinSize cache entries of at least this size are stored compressed
+	CacheMinSize = 4096
+)
+
+// encoders the encodings responses are compressed with, the first one wins on equal quality
+var encoders = []string{Zstd, Brotli, Gzip}
+
+// Negotiate returns the encoding the Accept-Encoding header prefers, "" when it accepts none of ours. A * only
+// stands for gzip, the one encoding every client decodes.
+func Negotiate(acceptEncoding string) string {
+	best, bestQuality := "", 0.0
+	for _, encoding := range encoders {
+		if q := quality(acceptEncoding, encoding); q > bestQuality {
+			best, bestQuality = encoding, q
+		}
+	}
+	return best
+}
+
+// quality returns the q value of encoding in the Accept-Encoding header, 0 when it is not accepted
+func quality(acceptEncoding, encoding string) float64 {
+	q, wildcard := -1.0, 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		value := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, ok = parseQuality(v); !ok {
+				continue
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case encoding:
+			q = value
+		case "*":
+			wildcard = value
+		}
+	}
+	if q >= 0 {
+		return q
+	}
+	if encoding == Gzip {
+		return wildcard
+	}
+	return 0
+}
+
+func parseQuality(v string) (float64, bool) {
+	q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || q < 0 || q > 1 {
+		return 0, false
+	}
+	return q, true
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	zstdWriters = sync.Pool{New: func() any {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+
+	// level 4 compresses about as fast as gzip and smaller, the higher levels are too slow for responses
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, 4) }}
+)
+
+// Writer compresses what is written to it, Flush sends what has been compressed so far
+type Writer interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// encoder is the writer of an encoding, it is reused by its pool
+type encoder interface {
+	Writer
+	Reset(w io.Writer)
+}
+
+// pooledWriter puts its encoder back into its pool on Close
+type pooledWriter struct {
+	encoder
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.encoder.Close()
+	w.encoder.Reset(nil)
+	w.pool.Put(w.encoder)
+	return err
+}
+
+// NewWriter returns a writer compressing into w, it must be closed to complete the stream
+func NewWriter(w io.Writer, encoding string) (Writer, error) {
+	var pool *sync.Pool
+	switch encoding {
+	case Gzip:
+		pool = &gzipWriters
+	case Zstd:
+		pool = &zstdWriters
+	case Brotli:
+		pool = &brotliWriters
+	default:
+		return nil, fmt.Errorf("unsupported encoding %s", encoding)
+	}
+	e := pool.Get().(encoder)
+	e.Reset(w)
+	return &pooledWriter{encoder: e, pool: pool}, nil
+}
+
+var (
+	// zstdMagic starts every zstd frame, no json value starts with it
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	cacheEncoder, _ = zstd.NewWriter(nil)
+	cacheDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// Pack compresses a cache entry of at least CacheMinSize bytes
+func Pack(data []byte) []byte {
+	if len(data) < CacheMinSize {
+		return data
+	}
+	return cacheEncoder.EncodeAll(data, make([]byte, 0, len(data)/4))
+}
+
+// Unpack returns the entry Pack was given, entries cached uncompressed are returned as they are
+func Unpack(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, zstdMagic) {
+		return data, nil
+	}
+	return cacheDecoder.DecodeAll(data, nil)
+}
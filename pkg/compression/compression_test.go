@@ -0,0 +1,119 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/labstack/echo/v4"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    Gzip,
+		"gzip, deflate, br":       Brotli,
+		"br;q=0.9, gzip":          Gzip,
+		"gzip, zstd":              Zstd,
+		"zstd;q=0.5, gzip":        Gzip,
+		"gzip;q=0, zstd;q=0.1":    Zstd,
+		"*":                       Gzip,
+		"br, *;q=0.2":             Brotli,
+		"deflate, *;q=0.2":        Gzip,
+		"GZIP;q=0.8, zstd;q=oops": Gzip,
+	}
+	for acceptEncoding, expected := range cases {
+		if encoding := Negotiate(acceptEncoding); encoding != expected {
+			t.Errorf("%q: expected %q, got %q", acceptEncoding, expected, encoding)
+		}
+	}
+}
+
+func TestPack(t *testing.T) {
+	small := []byte(`{"number":"0x1"}`)
+	if packed := Pack(small); !bytes.Equal(packed, small) {
+		t.Errorf("small entry should be stored as is, got %x", packed)
+	}
+
+	large := []byte(`[` + strings.Repeat(`"0x1234567890abcdef",`, CacheMinSize/10) + `"0x0"]`)
+	packed := Pack(large)
+	if len(packed) >= len(large) {
+		t.Errorf("large entry not compressed, %d >= %d bytes", len(packed), len(large))
+	}
+	for _, entry := range [][]byte{small, packed} {
+		unpacked, err := Unpack(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(unpacked, small) && !bytes.Equal(unpacked, large) {
+			t.Errorf("unexpected unpacked entry %.40s", unpacked)
+		}
+	}
+}
+
+func serve(acceptEncoding string, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.POST("/", handler, Middleware())
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	body := []byte(`{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat("ab", MinSize) + `"}`)
+	blob := func(c echo.Context) error {
+		return c.JSONBlob(http.StatusOK, body)
+	}
+
+	rec := serve("gzip", blob)
+	if rec.Header().Get(echo.HeaderContentEncoding) != Gzip {
+		t.Fatalf("expected a gzip response, got %q", rec.Header().Get(echo.HeaderContentEncoding))
+	}
+	r, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := io.ReadAll(r); !bytes.Equal(decoded, body) {
+		t.Errorf("unexpected gzip body %.40s", decoded)
+	}
+
+	rec = serve("zstd", func(c echo.Context) error {
+		return c.Stream(http.StatusOK, echo.MIMEApplicationJSON, bytes.NewReader(body))
+	})
+	d, err := zstd.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := io.ReadAll(d); rec.Header().Get(echo.HeaderContentEncoding) != Zstd || !bytes.Equal(decoded, body) {
+		t.Errorf("unexpected zstd response %q %.40s", rec.Header().Get(echo.HeaderContentEncoding), decoded)
+	}
+
+	rec = serve("gzip, br", blob)
+	if decoded, _ := io.ReadAll(brotli.NewReader(rec.Body)); rec.Header().Get(echo.HeaderContentEncoding) != Brotli || !bytes.Equal(decoded, body) {
+		t.Errorf("unexpected brotli response %q %.40s", rec.Header().Get(echo.HeaderContentEncoding), decoded)
+	}
+
+	rec = serve("gzip", func(c echo.Context) error {
+		return c.JSONBlob(http.StatusOK, []byte(`{"result":"0x1"}`))
+	})
+	if rec.Header().Get(echo.HeaderContentEncoding) != "" || rec.Body.String() != `{"result":"0x1"}` {
+		t.Errorf("small response should be sent as is, got %q", rec.Body.String())
+	}
+
+	rec = serve("zstd, br", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentEncoding, Brotli)
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, body)
+	})
+	if rec.Header().Get(echo.HeaderContentEncoding) != Brotli || !bytes.Equal(rec.Body.Bytes(), body) {
+		t.Errorf("encoded response should be passed through, got %q", rec.Header().Get(echo.HeaderContentEncoding))
+	}
+}
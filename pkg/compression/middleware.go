@@ -0,0 +1,134 @@
+package compression
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware compresses responses of at least MinSize bytes with the encoding the client prefers. Responses
+// which already have a Content-Encoding, e.g. compressed upstream responses, are passed through as they are.
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			encoding := Negotiate(c.Request().Header.Get(echo.HeaderAcceptEncoding))
+			if encoding == "" || c.IsWebSocket() || c.Request().Method == http.MethodHead {
+				return next(c)
+			}
+
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			w := &responseWriter{ResponseWriter: res.Writer, encoding: encoding}
+			res.Writer = w
+			defer func() {
+				w.Close()
+				res.Writer = w.ResponseWriter
+			}()
+			return next(c)
+		}
+	}
+}
+
+// responseWriter holds back the first MinSize bytes of a response to decide whether it is compressed
+type responseWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buff     bytes.Buffer
+	started  bool   // the header has been sent
+	writer   Writer // compresses the body once started, nil when sent as is
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.started || w.status != 0 {
+		return
+	}
+	w.status = status
+	if w.ResponseWriter.Header().Get(echo.HeaderContentEncoding) != "" {
+		w.start(false)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.started {
+		w.buff.Write(data)
+		if w.buff.Len() < MinSize {
+			return len(data), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.writer != nil {
+		return w.writer.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// start sends the header and the held back bytes, compressed when compress is set
+func (w *responseWriter) start(compress bool) error {
+	w.started = true
+	header := w.ResponseWriter.Header()
+	if compress && header.Get(echo.HeaderContentEncoding) == "" {
+		writer, err := NewWriter(w.ResponseWriter, w.encoding)
+		if err != nil {
+			return err
+		}
+		w.writer = writer
+		header.Set(echo.HeaderContentEncoding, w.encoding)
+		header.Del(echo.HeaderContentLength)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buff.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.writer != nil {
+		_, err = w.writer.Write(w.buff.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buff.Bytes())
+	}
+	w.buff = bytes.Buffer{}
+	return err
+}
+
+// Flush starts a streamed response compressed, its size is not known yet
+func (w *responseWriter) Flush() {
+	if !w.started {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.start(true); err != nil {
+			return
+		}
+	}
+	if w.writer != nil {
+		w.writer.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close sends a response smaller than MinSize as it is and completes a compressed one
+func (w *responseWriter) Close() error {
+	if !w.started {
+		if w.status == 0 {
+			return nil
+		}
+		return w.start(false)
+	}
+	if w.writer != nil {
+		return w.writer.Close()
+	}
+	return nil
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,394 @@
+package filter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/utils"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	TypeLog   = "log"
+	TypeBlock = "block"
+)
+
+// Methods are the eth filter methods answered by the Emulator instead of the node
+var Methods = []string{
+	"eth_newFilter",
+	"eth_newBlockFilter",
+	"eth_uninstallFilter",
+	"eth_getFilterChanges",
+	"eth_getFilterLogs",
+}
+
+// reorgRewind is how many blocks a poll reads again after the last block it returned was replaced
+const reorgRewind = 16
+
+// Head is the chain head the filters follow, see app.HeadTracker
+type Head interface {
+	Latest() uint64
+	Hash(number uint64) (string, bool)
+}
+
+type Config struct {
+	ChainName string
+	IdleTime  time.Duration // filters not polled for this long are removed, defaults to 5 minutes like geth
+	MaxBlocks uint64        // most blocks read by one poll, the rest is returned by the next polls, defaults to 128
+
+	// Call sends a call to the upstream and returns its result
+	Call func(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error)
+
+	// LogsHead returns the block number of the node eth_getLogs is sent to with criteria, log polls do not
+	// read past it so a lagging node does not skip logs. Nil trusts the head.
+	LogsHead func(ctx context.Context, criteria map[string]json.RawMessage) (uint64, error)
+}
+
+// State is a filter stored in redis
+type State struct {
+	Type      string          `json:"type"`
+	Criteria  json.RawMessage `json:"criteria,omitempty"` // eth_newFilter param of log filters
+	LastBlock uint64          `json:"last_block"`         // last block returned by eth_getFilterChanges
+	LastHash  string          `json:"last_hash,omitempty"`
+}
+
+// Emulator keeps eth filters in redis so they work across nodes behind the proxy, polls read the new blocks
+// since the last poll with eth_getLogs or the block hashes of the head
+type Emulator struct {
+	cfg  Config
+	rdb  redis.UniversalClient
+	head Head
+}
+
+func New(rdb redis.UniversalClient, head Head, cfg Config) *Emulator {
+	if cfg.IdleTime == 0 {
+		cfg.IdleTime = time.Minute * 5
+	}
+	if cfg.MaxBlocks == 0 {
+		cfg.MaxBlocks = 128
+	}
+	return &Emulator{cfg: cfg, rdb: rdb, head: head}
+}
+
+// key scopes the filters by api key, a filter id is not usable with another key
+func (e *Emulator) key(apiKey, id string) string {
+	return fmt.Sprintf("filter:%s:%s:%s", e.cfg.ChainName, apiKey, id)
+}
+
+// Handle answers the filter calls, ok is false for other calls
+func (e *Emulator) Handle(ctx context.Context, apiKey string, call *jsonrpc.JsonRpcSingleRequest) (resp []byte, ok bool, err error) {
+	var result interface{}
+	var rpcErr *jsonrpc.JsonRpcErr
+	switch call.Method {
+	case "eth_newFilter":
+		result, rpcErr, err = e.newFilter(ctx, apiKey, call)
+	case "eth_newBlockFilter":
+		result, rpcErr, err = e.newBlockFilter(ctx, apiKey)
+	case "eth_uninstallFilter":
+		result, rpcErr, err = e.uninstallFilter(ctx, apiKey, call)
+	case "eth_getFilterChanges":
+		result, rpcErr, err = e.getFilterChanges(ctx, apiKey, call)
+	case "eth_getFilterLogs":
+		result, rpcErr, err = e.getFilterLogs(ctx, apiKey, call)
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	if rpcErr != nil {
+		rpcErr.ID = call.ID
+		resp, err = json.Marshal(rpcErr)
+		return resp, true, err
+	}
+	rawResult, err := json.Marshal(result)
+	if err != nil {
+		return nil, true, err
+	}
+	resp, err = json.Marshal(jsonrpc.JsonRpcResponse{ID: call.ID, JsonRpcVersion: "2.0", Result: rawResult})
+	return resp, true, err
+}
+
+func (e *Emulator) newFilter(ctx context.Context, apiKey string, call *jsonrpc.JsonRpcSingleRequest) (interface{}, *jsonrpc.JsonRpcErr, error) {
+	var params []json.RawMessage
+	if err := json.Unmarshal(call.Params, &params); err != nil || len(params) == 0 {
+		return nil, jsonrpc.NewInvalidParamsError(nil, "expected the filter criteria as first param"), nil
+	}
+	var criteria map[string]json.RawMessage
+	if err := json.Unmarshal(params[0], &criteria); err != nil {
+		return nil, jsonrpc.NewInvalidParamsError(nil, "invalid filter criteria"), nil
+	}
+	if _, ok := criteria["blockHash"]; ok {
+		return nil, jsonrpc.NewInvalidParamsError(nil, "blockHash is not supported by filters, use eth_getLogs"), nil
+	}
+	return e.install(ctx, apiKey, State{Type: TypeLog, Criteria: params[0]})
+}
+
+func (e *Emulator) newBlockFilter(ctx context.Context, apiKey string) (interface{}, *jsonrpc.JsonRpcErr, error) {
+	return e.install(ctx, apiKey, State{Type: TypeBlock})
+}
+
+// install saves a new filter which starts at the current head
+func (e *Emulator) install(ctx context.Context, apiKey string, state State) (interface{}, *jsonrpc.JsonRpcErr, error) {
+	latest, err := e.latest(ctx)
+	if err != nil {
+		return nil, upstreamError(err), nil
+	}
+	state.LastBlock = latest
+	state.LastHash, _ = e.head.Hash(latest)
+
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return nil, nil, err
+	}
+	id := "0x" + hex.EncodeToString(b)
+	if err = e.save(ctx, apiKey, id, &state); err != nil {
+		return nil, nil, err
+	}
+	return id, nil, nil
+}
+
+func (e *Emulator) uninstallFilter(ctx context.Context, apiKey string, call *jsonrpc.JsonRpcSingleRequest) (interface{}, *jsonrpc.JsonRpcErr, error) {
+	id, rpcErr := filterID(call)
+	if rpcErr != nil {
+		return nil, rpcErr, nil
+	}
+	n, err := e.rdb.Del(ctx, e.key(apiKey, id)).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	return n > 0, nil, nil
+}
+
+func (e *Emulator) getFilterChanges(ctx context.Context, apiKey string, call *jsonrpc.JsonRpcSingleRequest) (interface{}, *jsonrpc.JsonRpcErr, error) {
+	id, rpcErr := filterID(call)
+	if rpcErr != nil {
+		return nil, rpcErr, nil
+	}
+	state, err := e.load(ctx, apiKey, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state == nil {
+		return nil, filterNotFound(), nil
+	}
+
+	latest, err := e.latest(ctx)
+	if err != nil {
+		return nil, upstreamError(err), nil
+	}
+	from, to := e.pollRange(state, latest)
+
+	var result interface{} = []string{}
+	if state.Type == TypeBlock && from <= to {
+		hashes := make([]string, 0, to-from+1)
+		for number := from; number <= to; number++ {
+			hash, err := e.blockHash(ctx, number)
+			if err != nil {
+				return nil, upstreamError(err), nil
+			}
+			hashes = append(hashes, hash)
+		}
+		result = hashes
+	}
+	if state.Type == TypeLog {
+		var logs json.RawMessage
+		logs, to, rpcErr = e.pollLogs(ctx, state.Criteria, from, to)
+		if rpcErr != nil {
+			return nil, rpcErr, nil
+		}
+		if logs != nil {
+			result = logs
+		}
+	}
+
+	if from <= to {
+		state.LastBlock = to
+		state.LastHash, _ = e.head.Hash(to)
+	}
+	if err = e.save(ctx, apiKey, id, state); err != nil {
+		return nil, nil, err
+	}
+	return result, nil, nil
+}
+
+func (e *Emulator) getFilterLogs(ctx context.Context, apiKey string, call *jsonrpc.JsonRpcSingleRequest) (interface{}, *jsonrpc.JsonRpcErr, error) {
+	id, rpcErr := filterID(call)
+	if rpcErr != nil {
+		return nil, rpcErr, nil
+	}
+	state, err := e.load(ctx, apiKey, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state == nil {
+		return nil, filterNotFound(), nil
+	}
+	if state.Type != TypeLog {
+		return nil, filterNotFound(), nil
+	}
+	if err = e.rdb.Expire(ctx, e.key(apiKey, id), e.cfg.IdleTime).Err(); err != nil {
+		return nil, nil, err
+	}
+	logs, err := e.cfg.Call(ctx, "eth_getLogs", state.Criteria)
+	if err != nil {
+		return nil, upstreamError(err), nil
+	}
+	return logs, nil, nil
+}
+
+// pollLogs reads the logs of from..to, nil when the criteria match none of the blocks. to is lowered to the
+// block of the node answering eth_getLogs when it is behind, the next poll reads the rest.
+func (e *Emulator) pollLogs(ctx context.Context, rawCriteria json.RawMessage, from, to uint64) (json.RawMessage, uint64, *jsonrpc.JsonRpcErr) {
+	criteria, empty, err := logCriteria(rawCriteria, from, to)
+	if err != nil {
+		return nil, to, jsonrpc.NewInvalidParamsError(nil, err.Error())
+	}
+	if empty {
+		return nil, to, nil
+	}
+	if e.cfg.LogsHead != nil {
+		logsHead, err := e.cfg.LogsHead(ctx, criteria)
+		if err != nil {
+			return nil, to, upstreamError(err)
+		}
+		if logsHead < to {
+			to = logsHead
+			if criteria, empty, err = logCriteria(rawCriteria, from, to); err != nil || empty {
+				return nil, to, nil
+			}
+		}
+	}
+	logs, err := e.cfg.Call(ctx, "eth_getLogs", criteria)
+	if err != nil {
+		return nil, to, upstreamError(err)
+	}
+	return logs, to, nil
+}
+
+// pollRange returns the blocks a poll reads, from > to when there is no new block. The last returned block
+// is read again with the blocks before it when it was replaced by a reorg.
+func (e *Emulator) pollRange(state *State, latest uint64) (from, to uint64) {
+	from = state.LastBlock + 1
+	if hash, ok := e.head.Hash(state.LastBlock); ok && state.LastHash != "" && hash != state.LastHash {
+		from = state.LastBlock - min(state.LastBlock, reorgRewind) + 1
+	}
+	to = latest
+	if to >= from && to-from >= e.cfg.MaxBlocks {
+		to = from + e.cfg.MaxBlocks - 1
+	}
+	return from, to
+}
+
+// latest returns the head block number, from the upstream until the head is known
+func (e *Emulator) latest(ctx context.Context) (uint64, error) {
+	if latest := e.head.Latest(); latest > 0 {
+		return latest, nil
+	}
+	result, err := e.cfg.Call(ctx, "eth_blockNumber")
+	if err != nil {
+		return 0, err
+	}
+	var number string
+	if err = json.Unmarshal(result, &number); err != nil {
+		return 0, err
+	}
+	return utils.ToUint64(number)
+}
+
+// blockHash returns the hash of a recent block from the head, older blocks are read from the upstream
+func (e *Emulator) blockHash(ctx context.Context, number uint64) (string, error) {
+	if hash, ok := e.head.Hash(number); ok {
+		return hash, nil
+	}
+	result, err := e.cfg.Call(ctx, "eth_getBlockByNumber", "0x"+strconv.FormatUint(number, 16), false)
+	if err != nil {
+		return "", err
+	}
+	var block struct {
+		Hash string `json:"hash"`
+	}
+	if err = json.Unmarshal(result, &block); err != nil {
+		return "", err
+	}
+	if block.Hash == "" {
+		return "", fmt.Errorf("block %d not found", number)
+	}
+	return block.Hash, nil
+}
+
+func (e *Emulator) load(ctx context.Context, apiKey, id string) (*State, error) {
+	data, err := e.rdb.Get(ctx, e.key(apiKey, id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// save stores the filter and restarts its idle time
+func (e *Emulator) save(ctx context.Context, apiKey, id string, state *State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return e.rdb.Set(ctx, e.key(apiKey, id), data, e.cfg.IdleTime).Err()
+}
+
+// logCriteria returns the eth_getLogs filter of a poll over from..to, empty is true when the criteria
+// toBlock is before from
+func logCriteria(rawCriteria json.RawMessage, from, to uint64) (criteria map[string]json.RawMessage, empty bool, err error) {
+	if from > to {
+		return nil, true, nil
+	}
+	if err = json.Unmarshal(rawCriteria, &criteria); err != nil {
+		return nil, false, errors.Wrap(err, "invalid filter criteria")
+	}
+	if rawTo, ok := criteria["toBlock"]; ok {
+		var toBlock string
+		if err = json.Unmarshal(rawTo, &toBlock); err != nil {
+			return nil, false, errors.Wrap(err, "invalid toBlock")
+		}
+		// tags keep following the head
+		if number, err := utils.ToUint64(toBlock); err == nil && strings.HasPrefix(toBlock, "0x") {
+			if number < from {
+				return nil, true, nil
+			}
+			to = min(to, number)
+		}
+	}
+	criteria["fromBlock"], _ = json.Marshal("0x" + strconv.FormatUint(from, 16))
+	criteria["toBlock"], _ = json.Marshal("0x" + strconv.FormatUint(to, 16))
+	return criteria, false, nil
+}
+
+func filterID(call *jsonrpc.JsonRpcSingleRequest) (string, *jsonrpc.JsonRpcErr) {
+	var params []string
+	if err := json.Unmarshal(call.Params, &params); err != nil || len(params) == 0 {
+		return "", jsonrpc.NewInvalidParamsError(nil, "expected the filter id as first param")
+	}
+	return params[0], nil
+}
+
+func filterNotFound() *jsonrpc.JsonRpcErr {
+	return &jsonrpc.JsonRpcErr{Code: -32000, Message: "filter not found"}
+}
+
+func upstreamError(err error) *jsonrpc.JsonRpcErr {
+	return &jsonrpc.JsonRpcErr{Code: -32000, Message: err.Error()}
+}
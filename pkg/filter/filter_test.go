@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type testHead map[uint64]string
+
+func (h testHead) Latest() uint64 { return 0 }
+
+func (h testHead) Hash(number uint64) (string, bool) {
+	hash, ok := h[number]
+	return hash, ok
+}
+
+func TestPollRange(t *testing.T) {
+	e := New(nil, testHead{100: "0xa", 150: "0xb"}, Config{MaxBlocks: 10})
+	tests := []struct {
+		state    State
+		latest   uint64
+		from, to uint64
+	}{
+		{State{LastBlock: 100, LastHash: "0xa"}, 105, 101, 105},
+		{State{LastBlock: 100, LastHash: "0xa"}, 100, 101, 100},
+		{State{LastBlock: 100, LastHash: "0xa"}, 200, 101, 110},
+		{State{LastBlock: 100, LastHash: "0xc"}, 105, 85, 94},
+		{State{LastBlock: 120}, 125, 121, 125},
+	}
+	for _, test := range tests {
+		from, to := e.pollRange(&test.state, test.latest)
+		if from != test.from || to != test.to {
+			t.Errorf("%+v at %d: expected %d-%d, got %d-%d", test.state, test.latest, test.from, test.to, from, to)
+		}
+	}
+}
+
+func TestLogCriteria(t *testing.T) {
+	tests := []struct {
+		criteria string
+		from, to uint64
+		expected string
+		empty    bool
+	}{
+		{`{"address":"0x01"}`, 16, 31, `{"address":"0x01","fromBlock":"0x10","toBlock":"0x1f"}`, false},
+		{`{"fromBlock":"0x1","toBlock":"latest"}`, 16, 31, `{"fromBlock":"0x10","toBlock":"0x1f"}`, false},
+		{`{"toBlock":"0x14"}`, 16, 31, `{"fromBlock":"0x10","toBlock":"0x14"}`, false},
+		{`{"toBlock":"0x5"}`, 16, 31, ``, true},
+		{`{}`, 17, 16, ``, true},
+	}
+	for _, test := range tests {
+		criteria, empty, err := logCriteria(json.RawMessage(test.criteria), test.from, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if empty != test.empty {
+			t.Errorf("%s %d-%d: expected empty %v", test.criteria, test.from, test.to, test.empty)
+			continue
+		}
+		if empty {
+			continue
+		}
+		data, _ := json.Marshal(criteria)
+		if string(data) != test.expected {
+			t.Errorf("%s %d-%d: expected %s, got %s", test.criteria, test.from, test.to, test.expected, data)
+		}
+	}
+}
+
+func TestPollLogsLaggingNode(t *testing.T) {
+	var logsHead uint64
+	var read map[string]json.RawMessage
+	e := New(nil, testHead{}, Config{
+		Call: func(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+			read = params[0].(map[string]json.RawMessage)
+			return json.RawMessage(`[]`), nil
+		},
+		LogsHead: func(ctx context.Context, criteria map[string]json.RawMessage) (uint64, error) {
+			return logsHead, nil
+		},
+	})
+	tests := []struct {
+		logsHead uint64
+		to       uint64
+		toBlock  string
+	}{
+		{120, 110, `"0x6e"`},
+		{105, 105, `"0x69"`},
+		{99, 99, ``},
+	}
+	for _, test := range tests {
+		logsHead, read = test.logsHead, nil
+		_, to, rpcErr := e.pollLogs(context.Background(), json.RawMessage(`{}`), 101, 110)
+		if rpcErr != nil {
+			t.Fatal(rpcErr.Message)
+		}
+		if to != test.to || string(read["toBlock"]) != test.toBlock {
+			t.Errorf("node at %d: expected to %d and toBlock %s, got %d and %s", test.logsHead, test.to, test.toBlock, to, read["toBlock"])
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"starnet/chain-api/pkg/filter"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/utils"
+)
+
+// SetFilters answers the filter methods over http with the emulator instead of the node
+func (h *JsonRpcHandler) SetFilters(filters *filter.Emulator) {
+	h.filters = filters
+}
+
+// filterBatchError rejects emulated filter calls in batches, the other calls of the batch go to the node
+func (h *JsonRpcHandler) filterBatchError(req *jsonrpc.JsonRpcRequest) *jsonrpc.JsonRpcErr {
+	if h.filters == nil || !req.IsBatchCall() {
+		return nil
+	}
+	for _, call := range req.GetBatchCall() {
+		if utils.In(call.Method, filter.Methods) {
+			return jsonrpc.NewInvalidParamsError(call.ID, "filter methods can not be sent in batch calls")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/proxy"
+	"starnet/chain-api/pkg/utils"
+	ratelimitv1 "starnet/chain-api/ratelimit/v1"
+	"starnet/starnet/constant"
+)
+
+// GrpcHandler 处理 cosmos sdk gRPC 和 gRPC-web 请求
+type GrpcHandler struct {
+	chain        constant.Chain
+	listen       string
+	allowMethods []string // full method patterns, e.g. /cosmos.bank.v1beta1.Query/*
+	denyMethods  []string // checked after allowMethods
+	proxy        *proxy.GrpcProxy
+	rateLimiter  *ratelimitv1.RateLimiter
+	logger       *zap.Logger
+	server       *grpc.Server
+}
+
+func NewGrpcHandler(
+	chain constant.Chain,
+	listen string,
+	allowMethods []string,
+	denyMethods []string,
+	proxy *proxy.GrpcProxy,
+	app *app.App,
+) *GrpcHandler {
+	h := &GrpcHandler{
+		chain:        chain,
+		listen:       listen,
+		allowMethods: allowMethods,
+		denyMethods:  denyMethods,
+		proxy:        proxy,
+		rateLimiter:  app.RateLimiter,
+		logger:       app.Logger.With(zap.String("chain", chain.Name), zap.Bool("grpc", true)),
+	}
+	h.server = newGrpcServer(h)
+	return h
+}
+
+// newGrpcServer relays every call of the server to the upstream through h
+func newGrpcServer(h *GrpcHandler) *grpc.Server {
+	return grpc.NewServer(
+		grpc.ForceServerCodec(proxy.RawCodec{}),
+		grpc.UnknownServiceHandler(h.stream),
+	)
+}
+
+// authorize checks the method lists and charges the api key, the returned error is a grpc status
+func (h *GrpcHandler) authorize(ctx context.Context, logger *zap.Logger, apiKey, fullMethod string) error {
+	if !utils.MatchAnyPath(h.allowMethods, fullMethod) || utils.MatchAnyPath(h.denyMethods, fullMethod) {
+		return status.Errorf(codes.PermissionDenied, "method %s is not allowed", fullMethod)
+	}
+
+	if apiKey == "" {
+		return status.Error(codes.Unauthenticated, "missing "+proxy.GrpcApiKeyHeader)
+	}
+
+	if err := h.rateLimiter.Allow(ctx, h.chain.ChainID, apiKey, 1); err != nil {
+		if errors.Is(err, ratelimitv1.ExceededRateLimitError) {
+			return status.Error(codes.ResourceExhausted, "too many requests")
+		}
+
+		if errors.Is(err, ratelimitv1.ApiKeyNotExistError) {
+			return status.Error(codes.Unauthenticated, "unauthorized")
+		}
+
+		logger.Error("internal error", zap.Error(err))
+		return status.Error(codes.Internal, "internal error")
+	}
+	return nil
+}
+
+func (h *GrpcHandler) stream(srv interface{}, stream grpc.ServerStream) error {
+	fullMethod, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "unknown method")
+	}
+	logger := h.logger.With(zap.String("method", fullMethod))
+
+	var apiKey string
+	if md, ok := metadata.FromIncomingContext(stream.Context()); ok {
+		if values := md.Get(proxy.GrpcApiKeyHeader); len(values) > 0 {
+			apiKey = values[0]
+		}
+	}
+	if err := h.authorize(stream.Context(), logger, apiKey, fullMethod); err != nil {
+		logger.Debug("grpc call rejected", zap.Error(err))
+		return err
+	}
+
+	return h.proxy.Forward(stream, fullMethod)
+}
+
+// Serve runs the grpc listener of the chain until it fails, without a listen address only grpc-web is served
+func (h *GrpcHandler) Serve() error {
+	if h.listen == "" {
+		// net.Listen would pick a random port on every interface
+		h.logger.Info("no grpc listen address, serving grpc-web only")
+		return nil
+	}
+	lis, err := net.Listen("tcp", h.listen)
+	if err != nil {
+		return err
+	}
+	h.logger.Info("grpc proxy listening", zap.String("listen", h.listen))
+	return h.server.Serve(lis)
+}
+
+// Shutdown stops accepting grpc calls and waits for the running ones, they are cancelled when ctx ends
+func (h *GrpcHandler) Shutdown(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		h.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		h.server.Stop()
+	}
+}
+
+// GrpcWeb serves binary grpc-web calls, the api key is taken from the path or the x-api-key header
+func (h *GrpcHandler) GrpcWeb(c echo.Context) error {
+	rawreq := c.Request()
+	logger := h.logger.With(zap.String("request_id", rawreq.Context().Value("request_id").(string)))
+
+	contentType := rawreq.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/grpc-web") || strings.HasPrefix(contentType, "application/grpc-web-text") {
+		return echo.ErrUnsupportedMediaType
+	}
+
+	fullMethod := "/" + c.Param("*")
+	apiKey := c.Param("apiKey")
+	if headerKey := rawreq.Header.Get(proxy.GrpcApiKeyHeader); headerKey != "" {
+		apiKey = headerKey
+	}
+	if err := h.authorize(rawreq.Context(), logger, apiKey, fullMethod); err != nil {
+		w := c.Response()
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		w.WriteHeader(http.StatusOK)
+		proxy.WriteGrpcWebTrailer(w, status.Convert(err))
+		return nil
+	}
+
+	body, err := io.ReadAll(rawreq.Body)
+	if err != nil {
+		return err
+	}
+
+	h.proxy.ForwardGrpcWeb(rawreq.Context(), logger, c.Response(), rawreq, fullMethod, body)
+	return nil
+}
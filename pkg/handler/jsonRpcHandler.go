@@ -8,7 +8,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"io"
+	"net/http"
 	"strings"
 	"time"
 
@@ -16,9 +17,14 @@ import (
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"starnet/chain-api/config"
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/broadcast"
+	"starnet/chain-api/pkg/filter"
 	"starnet/chain-api/pkg/jsonrpc"
 	"starnet/chain-api/pkg/proxy"
+	"starnet/chain-api/pkg/sanitize"
+	"starnet/chain-api/pkg/subscription"
 	"starnet/chain-api/pkg/utils"
 	ratelimitv1 "starnet/chain-api/ratelimit/v1"
 	"starnet/starnet/constant"
@@ -31,8 +37,20 @@ type JsonRpcHandler struct {
 	wsBlackMethods   []string // black list mode
 	justWhiteMethods []string // only apiKey in white list can request
 	erigonMethods    []string
+	methodCosts      map[string]int                                              // rate limit cost per method, defaults to 1
+	validator        func(req *jsonrpc.JsonRpcSingleRequest) *jsonrpc.JsonRpcErr // chain specific params check
+	sanitizer        *sanitize.Pipeline                                          // hides node internals in responses
+	broadcaster      *broadcast.Broadcaster                                      // nil sends broadcast calls like any other call
+	broadcastMethods []string
+	broadcastTargets []broadcast.Target
+	private          *PrivateTxConfig  // nil sends every transaction to the public upstream
+	filters          *filter.Emulator  // nil leaves the filter methods to the node
+	subscriptions    *subscription.Hub // nil relays every subscription to the upstream
+	requestTimeout   time.Duration     // of a client request including its upstream calls
 	proxy            *proxy.JsonRpcProxy
 	rateLimiter      *ratelimitv1.RateLimiter
+	websockets       *app.Websockets
+	background       *app.Background
 	logger           *zap.Logger
 	isDev            bool
 }
@@ -52,13 +70,99 @@ func NewJsonRpcHandler(
 		erigonMethods:    erigonMethods,
 		wsBlackMethods:   wsBlackMethods,
 		justWhiteMethods: justWhiteMethods,
+		requestTimeout:   defaultRequestTimeout,
 		proxy:            proxy,
 		rateLimiter:      app.RateLimiter,
+		websockets:       app.Websockets,
+		background:       app.Background,
 		logger:           app.Logger,
 		isDev:            app.Config.Log.IsDevelopment,
 	}
 }
 
+// defaultRequestTimeout bounds a client request unless the chain sets its own request timeout
+const defaultRequestTimeout = time.Second * 5
+
+// SetRequestTimeout sets the deadline of a client request including its upstream calls, 0 keeps the default
+func (h *JsonRpcHandler) SetRequestTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		h.requestTimeout = timeout
+	}
+}
+
+// SetMethodCosts sets the number of quota units charged for each method
+func (h *JsonRpcHandler) SetMethodCosts(costs map[string]int) {
+	h.methodCosts = costs
+}
+
+// SetValidator sets a chain specific check which runs on every call after the black list
+func (h *JsonRpcHandler) SetValidator(validator func(req *jsonrpc.JsonRpcSingleRequest) *jsonrpc.JsonRpcErr) {
+	h.validator = validator
+}
+
+// SetSanitizer sets the rules which rewrite http and websocket responses
+func (h *JsonRpcHandler) SetSanitizer(sanitizer *sanitize.Pipeline) {
+	h.sanitizer = sanitizer
+}
+
+// SetBroadcaster sends calls to methods to all targets in parallel instead of the proxy
+func (h *JsonRpcHandler) SetBroadcaster(broadcaster *broadcast.Broadcaster, methods []string, targets []broadcast.Target) {
+	h.broadcaster = broadcaster
+	h.broadcastMethods = methods
+	h.broadcastTargets = targets
+}
+
+// broadcast answers broadcast and broadcast status calls, ok is false for other calls
+func (h *JsonRpcHandler) broadcast(ctx context.Context, call *jsonrpc.JsonRpcSingleRequest) (resp []byte, ok bool, err error) {
+	if h.broadcaster == nil {
+		return nil, false, nil
+	}
+	switch {
+	case call.Method == broadcast.StatusMethod:
+		resp, err = h.broadcaster.StatusResponse(ctx, call)
+	case utils.In(call.Method, h.broadcastMethods):
+		resp, err = h.broadcaster.Broadcast(ctx, call, h.broadcastTargets)
+	default:
+		return nil, false, nil
+	}
+	return resp, true, err
+}
+
+// broadcastBatchError rejects broadcast calls in batches, they are only fanned out and recorded one by one
+func (h *JsonRpcHandler) broadcastBatchError(req *jsonrpc.JsonRpcRequest) *jsonrpc.JsonRpcErr {
+	if h.broadcaster == nil || !req.IsBatchCall() {
+		return nil
+	}
+	for _, call := range req.GetBatchCall() {
+		if call.Method == broadcast.StatusMethod || utils.In(call.Method, h.broadcastMethods) {
+			return jsonrpc.NewInvalidParamsError(call.ID, "broadcast methods can not be sent in batch calls")
+		}
+	}
+	return nil
+}
+
+// wsBroadcast answers a broadcast call of a websocket in the background, ok is false for other calls
+func (h *JsonRpcHandler) wsBroadcast(ctx context.Context, logger *zap.Logger, client *proxy.Client, req *jsonrpc.JsonRpcRequest) (ok bool) {
+	if req.IsBatchCall() || req.RequestType == jsonrpc.RequestTypePrivate || h.broadcaster == nil {
+		return false
+	}
+	call := req.GetSingleCall()
+	if call.Method != broadcast.StatusMethod && !utils.In(call.Method, h.broadcastMethods) {
+		return false
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, h.requestTimeout)
+		defer cancel()
+		resp, _, err := h.broadcast(ctx, call)
+		if err != nil {
+			logger.Error("fail to broadcast", zap.Error(err))
+			resp, _ = json.Marshal(jsonrpc.NewInternalServerError(call.ID))
+		}
+		client.Send(proxy.RespData{Data: resp, RequestMethod: call.Method})
+	}()
+	return true
+}
+
 func (h *JsonRpcHandler) validateReq(req *jsonrpc.JsonRpcSingleRequest, blackMethods []string) *jsonrpc.JsonRpcErr {
 	if req.Method == "" {
 		return jsonrpc.ParseError
@@ -68,10 +172,15 @@ func (h *JsonRpcHandler) validateReq(req *jsonrpc.JsonRpcSingleRequest, blackMet
 		return jsonrpc.NewUnsupportedMethodError(req.ID)
 	}
 
+	if h.validator != nil {
+		return h.validator(req)
+	}
+
 	return nil
 }
 
-func (h *JsonRpcHandler) bind(apiKey string, rawreq []byte, blackMethods, erigonMethods []string) (*jsonrpc.JsonRpcRequest, *jsonrpc.JsonRpcErr) {
+// bind parses and validates a call, private routes the private methods to the private relay
+func (h *JsonRpcHandler) bind(apiKey string, rawreq []byte, blackMethods, erigonMethods []string, private bool) (*jsonrpc.JsonRpcRequest, *jsonrpc.JsonRpcErr) {
 	req := jsonrpc.JsonRpcRequest{}
 	if err := json.Unmarshal(rawreq, &req); err != nil {
 		return nil, jsonrpc.ParseError
@@ -86,6 +195,10 @@ func (h *JsonRpcHandler) bind(apiKey string, rawreq []byte, blackMethods, erigon
 			if err := h.validateReq(&r, blackMethods); err != nil {
 				return nil, err
 			}
+			if private && utils.In(r.Method, h.private.Methods) {
+				// the other calls of the batch would leak the transaction to the public upstream or hit the relay
+				return nil, jsonrpc.NewInvalidParamsError(r.ID, "private transactions can not be sent in batch calls")
+			}
 			if utils.In(r.Method, erigonMethods) {
 				req.RequestType = jsonrpc.RequestTypeErigon
 				// cache save the request count
@@ -96,6 +209,10 @@ func (h *JsonRpcHandler) bind(apiKey string, rawreq []byte, blackMethods, erigon
 		if err := h.validateReq(req.GetSingleCall(), blackMethods); err != nil {
 			return nil, err
 		}
+		if private && utils.In(req.GetSingleCall().Method, h.private.Methods) {
+			req.RequestType = jsonrpc.RequestTypePrivate
+			return &req, nil
+		}
 		if utils.In(req.GetSingleCall().Method, erigonMethods) {
 			req.RequestType = jsonrpc.RequestTypeErigon
 			// cache save the request count
@@ -106,36 +223,22 @@ func (h *JsonRpcHandler) bind(apiKey string, rawreq []byte, blackMethods, erigon
 	return &req, nil
 }
 
-func (h *JsonRpcHandler) tendermintPathBind(apiKey, requestURI string, blackMethods []string) (*jsonrpc.TenderMintRequest, *jsonrpc.JsonRpcErr) {
-	uriList := strings.Split(requestURI, "/")
-	pathAllStr := uriList[len(uriList)-1]
-	urlQueryList := strings.Split(pathAllStr, "?")
-	var pathStr string
-	var urlQueryStr string
-	if len(urlQueryList) > 0 {
-		pathStr = urlQueryList[0]
-		for k, v := range urlQueryList {
-			if k == 0 {
-				continue
-			}
-			urlQueryStr += v
-		}
-	} else {
-		pathStr = pathAllStr
+// tendermintPathBind parses uri style calls, the route apiKey param holds the api key and the method, e.g. KEY/block
+func (h *JsonRpcHandler) tendermintPathBind(c echo.Context, blackMethods []string) (*jsonrpc.TenderMintRequest, *jsonrpc.JsonRpcErr) {
+	var path string
+	if keyPath := strings.SplitN(c.Param("apiKey"), "/", 2); len(keyPath) == 2 {
+		path = keyPath[1]
 	}
 
-	if pathStr == "/" || pathStr == apiKey {
+	req, err := jsonrpc.ParseTenderMintRequest(path, c.Request().URL.RawQuery)
+	if err != nil {
 		return nil, jsonrpc.ParseError
 	}
-	isBlack := utils.In(pathStr, blackMethods)
-	if isBlack {
+	if utils.In(req.Path, blackMethods) {
 		return nil, jsonrpc.NewUnsupportedMethodError(nil)
 	}
 
-	return &jsonrpc.TenderMintRequest{
-		Path:     pathStr,
-		URLQuery: "?" + urlQueryStr,
-	}, nil
+	return req, nil
 }
 
 func (h *JsonRpcHandler) rateLimit(ctx context.Context, logger *zap.Logger, apiKey string, n int) *jsonrpc.JsonRpcErr {
@@ -177,6 +280,11 @@ func (h *JsonRpcHandler) newLogger(c echo.Context) *zap.Logger {
 	}
 }
 
+// readRequestBody reads the request body, a body over limit bytes fails with a *http.MaxBytesError
+func readRequestBody(c echo.Context, limit int64) ([]byte, error) {
+	return io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, limit))
+}
+
 func (h *JsonRpcHandler) Http(c echo.Context) error {
 	logger := h.newLogger(c)
 
@@ -185,34 +293,75 @@ func (h *JsonRpcHandler) Http(c echo.Context) error {
 		return err
 	}
 
-	rawreq, err := ioutil.ReadAll(c.Request().Body)
+	rawreq, err := readRequestBody(c, config.DefaultMaxRequestBytes)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return c.JSON(200, jsonrpc.RequestTooLargeErr)
+		}
 		return err
 	}
 	logger.Debug("new request", zap.ByteString("rawreq", rawreq))
-	req, vErr := h.bind(apiKey, rawreq, h.httpBlackMethods, h.erigonMethods)
+	req, vErr := h.bind(apiKey, rawreq, h.httpBlackMethods, h.erigonMethods, h.isPrivate(apiKey, c.Request().Header))
 	if vErr != nil {
 		return c.JSON(200, vErr)
 	}
+	if vErr = h.filterBatchError(req); vErr != nil {
+		return c.JSON(200, vErr)
+	}
+	if vErr = h.broadcastBatchError(req); vErr != nil {
+		return c.JSON(200, vErr)
+	}
 
-	if rlErr := h.rateLimit(c.Request().Context(), logger, apiKey, req.Cost()); rlErr != nil {
+	if rlErr := h.rateLimit(c.Request().Context(), logger, apiKey, req.WeightedCost(h.methodCosts)); rlErr != nil {
 		logger.Debug("rate limit", zap.String("apiKey", apiKey), zap.Error(rlErr))
 		return c.JSON(200, rlErr)
 	}
 
-	ctx, _ := context.WithTimeout(c.Request().Context(), time.Second*5)
-	resp, err := h.proxy.HttpProxy(ctx, logger, req)
+	ctx, cancel := context.WithTimeout(c.Request().Context(), h.requestTimeout)
+	defer cancel()
+	var resp []byte
+	answered := false
+	if !req.IsBatchCall() && req.RequestType != jsonrpc.RequestTypePrivate {
+		resp, answered, err = h.broadcast(ctx, req.GetSingleCall())
+	}
+	if !answered && !req.IsBatchCall() && h.filters != nil {
+		resp, answered, err = h.filters.Handle(ctx, apiKey, req.GetSingleCall())
+	}
+	if !answered && h.streamable(req) {
+		// the request timeout bounds the upstream round trip only, a large response takes as long as it takes
+		// to be sent once its header arrived
+		streamCtx, cancelStream := context.WithCancel(c.Request().Context())
+		defer cancelStream()
+		timer := time.AfterFunc(h.requestTimeout, cancelStream)
+		body, err := h.proxy.HttpProxyStream(streamCtx, logger, req)
+		timer.Stop()
+		if err != nil {
+			logger.Error("fail to proxy request", zap.Error(err))
+			return c.JSON(200, jsonrpc.NewInternalServerError(nil))
+		}
+		defer body.Close()
+		return c.Stream(200, echo.MIMEApplicationJSON, body)
+	}
+	if !answered {
+		resp, err = h.proxy.HttpProxy(ctx, logger, req)
+	}
 	if err != nil {
 		logger.Error("fail to proxy request", zap.Error(err))
 		return c.JSON(200, jsonrpc.NewInternalServerError(nil))
 	}
+	if req.RequestType == jsonrpc.RequestTypePrivate {
+		h.watchPrivateTx(logger, req.GetSingleCall(), resp)
+	}
 
-	if req.GetSingleCall() != nil {
-		resp, err = h.clearInfo(c, resp, req.GetSingleCall().Method)
-		if err != nil {
-			logger.Error("fail to clear sensitive info", zap.Error(err))
-			return c.JSON(200, jsonrpc.NewInternalServerError(nil))
-		}
+	if req.IsBatchCall() {
+		resp, err = h.sanitizer.ApplyBatch(req.GetBatchCall(), resp)
+	} else {
+		resp, err = h.sanitizer.Apply(req.GetSingleCall().Method, resp)
+	}
+	if err != nil {
+		logger.Error("fail to sanitize response", zap.Error(err))
+		return c.JSON(200, jsonrpc.NewInternalServerError(nil))
 	}
 
 	logger.Debug("got response", zap.ByteString("resp", resp))
@@ -220,6 +369,23 @@ func (h *JsonRpcHandler) Http(c echo.Context) error {
 	return c.JSONBlob(200, resp)
 }
 
+// streamable reports whether the upstream response of req can be piped to the client as it arrives, responses
+// of private transactions and sanitized calls are read first
+func (h *JsonRpcHandler) streamable(req *jsonrpc.JsonRpcRequest) bool {
+	if req.RequestType == jsonrpc.RequestTypePrivate {
+		return false
+	}
+	if !req.IsBatchCall() {
+		return !h.sanitizer.Matches(req.GetSingleCall().Method)
+	}
+	for _, call := range req.GetBatchCall() {
+		if h.sanitizer.Matches(call.Method) {
+			return false
+		}
+	}
+	return true
+}
+
 func (h *JsonRpcHandler) TendermintHttp(c echo.Context) error {
 	logger := h.newLogger(c)
 
@@ -228,7 +394,7 @@ func (h *JsonRpcHandler) TendermintHttp(c echo.Context) error {
 		return err
 	}
 
-	tenderMintRequest, vErr := h.tendermintPathBind(apiKey, c.Request().RequestURI, h.httpBlackMethods)
+	tenderMintRequest, vErr := h.tendermintPathBind(c, h.httpBlackMethods)
 	if vErr != nil {
 		return c.JSON(200, vErr)
 	}
@@ -238,18 +404,21 @@ func (h *JsonRpcHandler) TendermintHttp(c echo.Context) error {
 		return c.JSON(200, rlErr)
 	}
 
-	ctx, cancelFunc := context.WithTimeout(c.Request().Context(), time.Second*5)
+	ctx, cancelFunc := context.WithTimeout(c.Request().Context(), h.requestTimeout)
 	defer cancelFunc()
 
-	resp, err := h.proxy.TendermintProxy(ctx, logger, *tenderMintRequest)
+	resp, broadcasted, err := h.broadcast(ctx, tenderMintRequest.JsonRpcCall())
+	if !broadcasted {
+		resp, err = h.proxy.TendermintProxy(ctx, logger, *tenderMintRequest)
+	}
 	if err != nil {
 		logger.Error("fail to proxy request", zap.Error(err))
 		return c.JSON(200, jsonrpc.NewInternalServerError(nil))
 	}
 
-	resp, err = h.clearInfo(c, resp, c.Request().RequestURI)
+	resp, err = h.sanitizer.Apply(tenderMintRequest.Path, resp)
 	if err != nil {
-		logger.Error("fail to clear sensitive info", zap.Error(err))
+		logger.Error("fail to sanitize response", zap.Error(err))
 		return c.JSON(200, jsonrpc.NewInternalServerError(nil))
 	}
 
@@ -265,11 +434,20 @@ func (h *JsonRpcHandler) handleWs(c echo.Context, logger *zap.Logger) error {
 		return err
 	}
 
+	private := h.isPrivate(apiKey, c.Request().Header)
+
 	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		return err
 	}
 	defer ws.Close()
+	ws.SetReadLimit(config.DefaultMaxRequestBytes)
+	done, ok := h.websockets.Track(func() { app.GoAway(ws) }, func() { ws.Close() })
+	if !ok {
+		app.GoAway(ws)
+		return nil
+	}
+	defer done()
 
 	logger.Debug("Upgraded to WebSocket protocol")
 
@@ -284,14 +462,16 @@ func (h *JsonRpcHandler) handleWs(c echo.Context, logger *zap.Logger) error {
 					return
 				}
 
-				// cosmos rpc hide the node ip
-				if strings.Contains(string(resp.Data), "node_info") {
-					resp.RequestMethod = "status"
+				var newData []byte
+				var err error
+				if resp.BatchCalls != nil {
+					newData, err = h.sanitizer.ApplyBatch(resp.BatchCalls, resp.Data)
+				} else {
+					newData, err = h.sanitizer.Apply(resp.RequestMethod, resp.Data)
 				}
-
-				newData, err := h.clearInfo(c, resp.Data, resp.RequestMethod)
 				if err != nil {
-					logger.Error("fail to clear sensitive info", zap.Error(err))
+					logger.Error("fail to sanitize response", zap.Error(err))
+					newData, _ = json.Marshal(jsonrpc.NewInternalServerError(nil))
 				}
 				resp.Data = newData
 
@@ -317,6 +497,13 @@ func (h *JsonRpcHandler) handleWs(c echo.Context, logger *zap.Logger) error {
 	}
 	defer upstreamConn.Close()
 
+	subs := make(map[string]bool)
+	defer func() {
+		for id := range subs {
+			h.subscriptions.Unsubscribe(id)
+		}
+	}()
+
 	resp := func(logger *zap.Logger, msg []byte) {
 		logger.Debug("response", zap.ByteString("rawresp", msg))
 		sendCh <- proxy.RespData{Data: msg}
@@ -337,23 +524,41 @@ func (h *JsonRpcHandler) handleWs(c echo.Context, logger *zap.Logger) error {
 
 		logger.Debug("new request", zap.ByteString("rawreq", rawreq))
 
-		req, vErr := h.bind(apiKey, rawreq, h.wsBlackMethods, h.erigonMethods)
+		req, vErr := h.bind(apiKey, rawreq, h.wsBlackMethods, h.erigonMethods, private)
+		if vErr == nil {
+			vErr = h.broadcastBatchError(req)
+		}
 		if vErr != nil {
 			respJSON(logger, vErr)
 			continue
 		}
 
 		ctx, _ := context.WithTimeout(c.Request().Context(), time.Second*2)
-		if rlErr := h.rateLimit(ctx, logger, apiKey, req.Cost()); rlErr != nil {
+		if rlErr := h.rateLimit(ctx, logger, apiKey, req.WeightedCost(h.methodCosts)); rlErr != nil {
 			respJSON(logger, rlErr)
 			continue
 		}
 
+		if subResp, start, ok := h.subscribe(req, subs, client); ok {
+			// the writer takes the response before the notifications, sendCh is unbuffered
+			respJSON(logger, subResp)
+			if start != nil {
+				start()
+			}
+			continue
+		}
+		if h.wsBroadcast(c.Request().Context(), logger, client, req) {
+			continue
+		}
+
 		if err = upstreamConn.Send(c.Request().Context(), logger, req); err != nil {
 			logger.Error("fail to proxy request", zap.Error(err))
 			respJSON(logger, jsonrpc.NewInternalServerError(nil))
 			return err
 		}
+		if req.RequestType == jsonrpc.RequestTypePrivate {
+			h.watchPrivateTx(logger, req.GetSingleCall(), nil)
+		}
 	}
 }
 
@@ -365,38 +570,3 @@ func (h *JsonRpcHandler) Ws(c echo.Context) error {
 	}
 	return nil
 }
-
-// clearInfo cosmos status api hide the rpc ip address
-func (h *JsonRpcHandler) clearInfo(c echo.Context, raw []byte, requestPath string) ([]byte, error) {
-	if !strings.Contains(c.Request().RequestURI, "tendermint") {
-		return raw, nil
-	}
-	var result []byte
-	var err error
-	if strings.Contains(requestPath, "status") && strings.Contains(string(raw), "rpc_address") {
-		var rawMap map[string]interface{}
-		if err := json.Unmarshal(raw, &rawMap); err != nil {
-			return nil, nil
-		}
-		changeRPCAddress(rawMap)
-		result, err = json.Marshal(rawMap)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return raw, nil
-	}
-	return result, nil
-}
-
-func changeRPCAddress(rawMap map[string]interface{}) {
-	if result, ok := rawMap["result"].(map[string]interface{}); ok {
-		if nodeInfo, ok := result["node_info"].(map[string]interface{}); ok {
-			if other, ok := nodeInfo["other"].(map[string]interface{}); ok {
-				if _, ok := other["rpc_address"]; ok {
-					other["rpc_address"] = ""
-				}
-			}
-		}
-	}
-}
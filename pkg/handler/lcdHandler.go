@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/proxy"
+	ratelimitv1 "starnet/chain-api/ratelimit/v1"
+	"starnet/starnet/constant"
+)
+
+// LcdHandler 处理 cosmos sdk rest api (lcd / grpc-gateway) 请求
+type LcdHandler struct {
+	chain       constant.Chain
+	allowPaths  []string // path prefixes, empty allows all paths
+	denyPaths   []string // path prefixes, checked after allowPaths
+	writePaths  []string // path prefixes which accept POST, e.g. tx broadcast
+	proxy       *proxy.LcdProxy
+	rateLimiter *ratelimitv1.RateLimiter
+	logger      *zap.Logger
+}
+
+func NewLcdHandler(
+	chain constant.Chain,
+	allowPaths []string,
+	denyPaths []string,
+	writePaths []string,
+	proxy *proxy.LcdProxy,
+	app *app.App,
+) *LcdHandler {
+	return &LcdHandler{
+		chain:       chain,
+		allowPaths:  allowPaths,
+		denyPaths:   denyPaths,
+		writePaths:  writePaths,
+		proxy:       proxy,
+		rateLimiter: app.RateLimiter,
+		logger:      app.Logger,
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *LcdHandler) isAllowed(method, reqPath string) error {
+	switch method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !hasAnyPrefix(reqPath, h.writePaths) {
+			return echo.ErrMethodNotAllowed
+		}
+	default:
+		return echo.ErrMethodNotAllowed
+	}
+	if len(h.allowPaths) > 0 && !hasAnyPrefix(reqPath, h.allowPaths) {
+		return echo.ErrForbidden
+	}
+	if hasAnyPrefix(reqPath, h.denyPaths) {
+		return echo.ErrForbidden
+	}
+	return nil
+}
+
+func (h *LcdHandler) rateLimit(ctx context.Context, logger *zap.Logger, apiKey string) error {
+	if err := h.rateLimiter.Allow(ctx, h.chain.ChainID, apiKey, 1); err != nil {
+		if errors.Is(err, ratelimitv1.ExceededRateLimitError) {
+			return echo.ErrTooManyRequests
+		}
+
+		if errors.Is(err, ratelimitv1.ApiKeyNotExistError) {
+			return echo.ErrUnauthorized
+		}
+
+		logger.Error("internal error", zap.Error(err))
+		return echo.ErrInternalServerError
+	}
+	return nil
+}
+
+func (h *LcdHandler) Lcd(c echo.Context) error {
+	rawreq := c.Request()
+	logger := h.logger.With(zap.String("chain", h.chain.Name), zap.String("request_id", rawreq.Context().Value("request_id").(string)))
+
+	apiKey := c.Param("apiKey")
+	// clean the path so prefix lists can not be bypassed with dot segments
+	reqPath := path.Clean("/" + c.Param("*"))
+	if err := h.isAllowed(rawreq.Method, reqPath); err != nil {
+		logger.Debug("lcd path rejected", zap.String("method", rawreq.Method), zap.String("path", reqPath))
+		return err
+	}
+
+	if err := h.rateLimit(rawreq.Context(), logger, apiKey); err != nil {
+		logger.Debug("rate limit", zap.String("apiKey", apiKey), zap.Error(err))
+		return err
+	}
+
+	body, err := io.ReadAll(rawreq.Body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancelFunc := context.WithTimeout(rawreq.Context(), time.Second*10)
+	defer cancelFunc()
+
+	resp, err := h.proxy.Proxy(ctx, logger, &proxy.LcdRequest{
+		Method:   rawreq.Method,
+		Path:     reqPath,
+		RawQuery: rawreq.URL.RawQuery,
+		Header:   rawreq.Header,
+		Body:     body,
+	})
+	if err != nil {
+		logger.Error("fail to proxy lcd request", zap.Error(err))
+		return echo.ErrBadGateway
+	}
+
+	return c.Blob(resp.StatusCode, resp.ContentType, resp.Body)
+}
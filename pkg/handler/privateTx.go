@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/proxy"
+	"starnet/chain-api/pkg/utils"
+
+	"go.uber.org/zap"
+)
+
+// PrivateTxHeader true sends the transactions of the request to the private relay, false to the public upstream
+const PrivateTxHeader = "X-Private-Tx"
+
+// PrivateTxConfig routes transactions to a private relay, e.g. a mev protection rpc, instead of the public mempool
+type PrivateTxConfig struct {
+	Methods        []string      // e.g. eth_sendRawTransaction
+	ApiKeys        []string      // keys whose transactions go to the relay without the header
+	FallbackBlocks int64         // broadcast publicly when not mined after this many blocks, 0 never falls back
+	BlockTime      time.Duration // interval of the inclusion checks
+	TxHash         func(call *jsonrpc.JsonRpcSingleRequest) (string, error)
+}
+
+// SetPrivateTx enables the private relay, the relay url is the HttpPrivateUpstream of the proxy
+func (h *JsonRpcHandler) SetPrivateTx(cfg *PrivateTxConfig) {
+	h.private = cfg
+}
+
+func (h *JsonRpcHandler) isPrivate(apiKey string, header http.Header) bool {
+	if h.private == nil {
+		return false
+	}
+	if private, err := strconv.ParseBool(header.Get(PrivateTxHeader)); err == nil {
+		return private
+	}
+	return utils.In(apiKey, h.private.ApiKeys)
+}
+
+// watchPrivateTx starts the public fallback of a transaction accepted by the relay, resp is nil over ws where
+// the relay response is not known here
+func (h *JsonRpcHandler) watchPrivateTx(logger *zap.Logger, call *jsonrpc.JsonRpcSingleRequest, resp []byte) {
+	if h.private.FallbackBlocks <= 0 {
+		return
+	}
+	if resp != nil {
+		relayResp := proxy.UpstreamJsonRpcResponse{}
+		if err := json.Unmarshal(resp, &relayResp); err != nil || len(relayResp.Error) > 0 {
+			return
+		}
+	}
+	hash, err := h.private.TxHash(call)
+	if err != nil {
+		logger.Warn("no fallback for private transaction", zap.Error(err))
+		return
+	}
+	fallbackCall := *call
+	h.background.Go(func(ctx context.Context) {
+		h.privateFallback(ctx, logger.With(zap.String("hash", hash)), fallbackCall, hash)
+	})
+}
+
+// privateFallback broadcasts a private transaction publicly when it is not mined within FallbackBlocks, it gives
+// up when ctx ends
+func (h *JsonRpcHandler) privateFallback(ctx context.Context, logger *zap.Logger, call jsonrpc.JsonRpcSingleRequest, hash string) {
+	// gives up when the public upstream stops following the chain
+	ctx, cancel := context.WithTimeout(ctx, h.private.BlockTime*time.Duration(h.private.FallbackBlocks*4))
+	defer cancel()
+	start, err := h.publicBlockNumber(ctx, logger)
+	if err != nil {
+		logger.Warn("failed to read the block number for the private transaction fallback", zap.Error(err))
+		return
+	}
+
+	ticker := time.NewTicker(h.private.BlockTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Warn("gave up the private transaction fallback", zap.Error(ctx.Err()))
+			return
+		case <-ticker.C:
+		}
+
+		receipt, err := h.proxy.Call(ctx, logger, "eth_getTransactionReceipt", hash)
+		if err == nil && len(receipt) > 0 && string(receipt) != "null" {
+			return
+		}
+		number, err := h.publicBlockNumber(ctx, logger)
+		if err != nil || number < start+uint64(h.private.FallbackBlocks) {
+			continue
+		}
+
+		logger.Info("private transaction not mined, broadcasting publicly", zap.Uint64("from_block", start), zap.Uint64("block", number))
+		sendCtx, cancelSend := context.WithTimeout(context.Background(), time.Second*10)
+		if h.broadcaster != nil {
+			_, err = h.broadcaster.Broadcast(sendCtx, &call, h.broadcastTargets)
+		} else {
+			_, err = h.proxy.HttpProxy(sendCtx, logger, jsonrpc.NewSingleRequest(&call))
+		}
+		cancelSend()
+		if err != nil {
+			logger.Error("failed to broadcast private transaction publicly", zap.Error(err))
+		}
+		return
+	}
+}
+
+func (h *JsonRpcHandler) publicBlockNumber(ctx context.Context, logger *zap.Logger) (uint64, error) {
+	result, err := h.proxy.Call(ctx, logger, "eth_blockNumber")
+	if err != nil {
+		return 0, err
+	}
+	var number string
+	if err = json.Unmarshal(result, &number); err != nil {
+		return 0, err
+	}
+	return utils.ToUint64(number)
+}
@@ -2,7 +2,9 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,16 +12,22 @@ import (
 	"net/http"
 	"starnet/chain-api/config"
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/breaker"
+	"starnet/chain-api/pkg/broadcast"
+	"starnet/chain-api/pkg/chaintype"
+	"starnet/chain-api/pkg/hedge"
+	"starnet/chain-api/pkg/jsonrpc"
 	"starnet/chain-api/pkg/prometheus"
+	"starnet/chain-api/pkg/sanitize"
+	"starnet/chain-api/pkg/upstreamauth"
 	"starnet/chain-api/pkg/utils"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/itchyny/gojq"
 	"github.com/labstack/echo/v4"
-	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"go.uber.org/zap"
 )
@@ -28,61 +36,100 @@ type rpcNode struct {
 	config.RpcNode
 	HttpHealth       atomic.Bool
 	WsHealth         atomic.Bool
-	ExtraWriteHealth atomic.Bool // tron network only
+	ExtraWriteHealth atomic.Bool
+	BlockNumber      atomic.Uint64 // of the http endpoint at the last health check
+	BlockLag         atomic.Uint64 // blocks the http endpoint was behind the head at the last health check
+
+	// breakers stop the traffic to an endpoint failing between two health checks
+	HttpBreaker       *breaker.Breaker
+	ExtraWriteBreaker *breaker.Breaker
+}
+
+// inPool reports whether the node serves requests of the pool
+func (n *rpcNode) inPool(pool string) bool {
+	return n.Pool == "" || n.Pool == pool
+}
+
+// httpReady reports whether the http endpoint is healthy and its breaker lets calls through
+func (n *rpcNode) httpReady() bool {
+	return n.HttpHealth.Load() && n.HttpBreaker.Ready()
+}
+
+func (n *rpcNode) extraWriteReady() bool {
+	return n.ExtraWriteHealth.Load() && n.ExtraWriteBreaker.Ready()
 }
 
 type RpcHandler struct {
-	config                *config.ChainConfig
-	nodes                 []*rpcNode
-	nodeErrorCounts       []int
-	jqQuery               *gojq.Query
-	tronExtraWriteJqQuery *gojq.Query
-	logger                *zap.Logger
-	app                   *app.App
+	config           *config.ChainConfig
+	nodes            []*rpcNode
+	nodeErrorCounts  []int
+	chain            *chaintype.Chain
+	sanitizer        *sanitize.Pipeline
+	broadcaster      *broadcast.Broadcaster // nil when the chain has no broadcast methods
+	next             atomic.Uint64          // round robin counter
+	sticky           sync.Map               // pool:client ip => stickyNode
+	headTracker      app.HeadTracker        // evm chains only
+	quorumMismatches sync.Map               // method => *atomic.Int64
+	latency          *hedge.Latency         // of the http requests, the hedge delay is its p95
+	logger           *zap.Logger
+	app              *app.App
 }
 
 func NewRpcHandler(config *config.ChainConfig, logger *zap.Logger, app *app.App) (*RpcHandler, error) {
-	if config.BlockNumberResultExtractor != "jq" {
-		return nil, fmt.Errorf("unsupported block number result extractor: %s, only jq is supported", config.BlockNumberResultExtractor)
-	}
-	query, err := gojq.Parse(config.BlockNumberResultExpression)
+	chain, err := chaintype.New(config)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse block number result expression")
+		return nil, err
 	}
-
-	var tronExtraWriteJqQuery *gojq.Query
-	if config.ChainType == "tron" {
-		tronExtraWriteJqQuery, err = gojq.Parse(".block_header.raw_data.number")
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to parse tron extra write jq query")
-		}
+	sanitizer, err := sanitize.New(config.Sanitize)
+	if err != nil {
+		return nil, err
 	}
 
 	h := &RpcHandler{
-		config:                config,
-		jqQuery:               query,
-		tronExtraWriteJqQuery: tronExtraWriteJqQuery,
-		nodes:                 make([]*rpcNode, len(config.Nodes)),
-		nodeErrorCounts:       make([]int, len(config.Nodes)),
-		logger:                logger,
-		app:                   app,
+		config:          config,
+		chain:           chain,
+		sanitizer:       sanitizer,
+		nodes:           make([]*rpcNode, len(config.Nodes)),
+		nodeErrorCounts: make([]int, len(config.Nodes)),
+		latency:         hedge.NewLatency(),
+		logger:          logger,
+		app:             app,
+	}
+	if len(config.BroadcastMethods) > 0 {
+		h.broadcaster = broadcast.New(app.Rdb, logger, broadcast.Config{
+			ChainName:  config.ChainName,
+			HttpClient: chain.HttpClient,
+			Background: app.Background,
+			TxHash:     chain.TxHash,
+		})
 	}
 	for i, node := range config.Nodes {
+		if err = upstreamauth.Validate(node.Auth); err != nil {
+			return nil, fmt.Errorf("node %d: %w", i, err)
+		}
 		h.nodes[i] = &rpcNode{
-			RpcNode:          node,
-			HttpHealth:       atomic.Bool{},
-			WsHealth:         atomic.Bool{},
-			ExtraWriteHealth: atomic.Bool{},
+			RpcNode:           node,
+			HttpHealth:        atomic.Bool{},
+			WsHealth:          atomic.Bool{},
+			ExtraWriteHealth:  atomic.Bool{},
+			HttpBreaker:       breaker.New(breaker.Config{}),
+			ExtraWriteBreaker: breaker.New(breaker.Config{}),
 		}
 		h.nodes[i].HttpHealth.Store(node.Http != "")
 		h.nodes[i].WsHealth.Store(node.Ws != "")
 		h.nodes[i].ExtraWriteHealth.Store(node.ExtraWrite != "")
 	}
+	if config.ChainType == (chaintype.Evm{}).Name() {
+		h.headTracker = h.evmHeadTracker()
+	}
 
 	go func() {
 		for {
 			h.checkNodesHealthy()
 			h.reportNodeErrors()
+			h.reportQuorumMismatches()
+			h.reportBreakers()
+			h.cleanSticky()
 			time.Sleep(time.Minute)
 		}
 	}()
@@ -90,27 +137,79 @@ func NewRpcHandler(config *config.ChainConfig, logger *zap.Logger, app *app.App)
 	return h, nil
 }
 
-func (h *RpcHandler) getHealthyNode() (*rpcNode, error) {
+// getHealthyNode returns the first healthy node of the pool, one with an open breaker when every node has one
+func (h *RpcHandler) getHealthyNode(pool string) (*rpcNode, error) {
+	var fallback *rpcNode
 	for _, node := range h.nodes {
-		if node.HttpHealth.Load() {
+		if node.inPool(pool) && node.httpReady() {
 			return node, nil
 		}
+		if fallback == nil && node.inPool(pool) && node.HttpHealth.Load() {
+			fallback = node
+		}
 	}
-	return nil, fmt.Errorf("no healthy HTTP RPC node found")
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("no healthy HTTP RPC node found in %s pool", pool)
 }
 
-func (h *RpcHandler) getHealthyExtraWriteNode() (*rpcNode, error) {
+func (h *RpcHandler) getHealthyExtraWriteNode(pool string) (*rpcNode, error) {
+	var fallback *rpcNode
 	for _, node := range h.nodes {
-		if node.ExtraWriteHealth.Load() {
+		if node.inPool(pool) && node.extraWriteReady() {
 			return node, nil
 		}
+		if fallback == nil && node.inPool(pool) && node.ExtraWriteHealth.Load() {
+			fallback = node
+		}
 	}
-	return nil, fmt.Errorf("no healthy extra write rpc node found")
+	if fallback != nil {
+		return fallback, nil
+	}
+	return nil, fmt.Errorf("no healthy extra write rpc node found in %s pool", pool)
+}
+
+// broadcastTargets are the healthy nodes of the write pool and the public relays
+func (h *RpcHandler) broadcastTargets() []broadcast.Target {
+	var targets []broadcast.Target
+	for _, node := range h.nodes {
+		if node.inPool(config.NodePoolWrite) && node.httpReady() {
+			targets = append(targets, broadcast.Target{Name: node.Name, Url: node.Http, Auth: node.Auth})
+		}
+	}
+	for _, relay := range h.config.BroadcastRelays {
+		targets = append(targets, broadcast.Target{Name: relay, Url: relay})
+	}
+	return targets
+}
+
+// serveBroadcast answers broadcast and broadcast status calls, it returns false for other calls
+func (h *RpcHandler) serveBroadcast(c echo.Context, call *jsonrpc.JsonRpcSingleRequest, logger *zap.Logger) (bool, error) {
+	if h.broadcaster == nil {
+		return false, nil
+	}
+
+	var resp []byte
+	var err error
+	switch {
+	case call.Method == broadcast.StatusMethod:
+		resp, err = h.broadcaster.StatusResponse(c.Request().Context(), call)
+	case utils.In(call.Method, h.config.BroadcastMethods):
+		resp, err = h.broadcaster.Broadcast(c.Request().Context(), call, h.broadcastTargets())
+	default:
+		return false, nil
+	}
+	if err != nil {
+		logger.Error("failed to broadcast", zap.String("method", call.Method), zap.Error(err))
+		return true, internalServerError
+	}
+	return true, c.JSONBlob(http.StatusOK, resp)
 }
 
 func (h *RpcHandler) getHealthyWsNode() (*rpcNode, error) {
 	for _, node := range h.nodes {
-		if node.WsHealth.Load() {
+		if node.inPool(config.NodePoolRead) && node.WsHealth.Load() {
 			return node, nil
 		}
 	}
@@ -119,48 +218,169 @@ func (h *RpcHandler) getHealthyWsNode() (*rpcNode, error) {
 
 var internalServerError = fmt.Errorf("Internal Server Error")
 
+// requestPool returns the node pool which serves the request
+func (h *RpcHandler) requestPool(r *http.Request, path string) string {
+	if h.chain.IsWriteRequest(r, path) {
+		return config.NodePoolWrite
+	}
+	return config.NodePoolRead
+}
+
 func (h *RpcHandler) ExtraWriteHttp(c echo.Context) error {
+	defer h.setRequestTimeout(c)()
 	rawreq := c.Request()
 	path := strings.TrimLeft(strings.TrimPrefix(rawreq.URL.Path, "/ew_rpc/"+h.config.ChainName+"/"+h.app.RpcConfig.ApiKey), "/")
 	logger := h.logger.With(zap.String("id", rawreq.Context().Value("request_id").(string)))
-	node, err := h.getHealthyExtraWriteNode()
+	rawreq.Body = http.MaxBytesReader(c.Response(), rawreq.Body, h.config.MaxRequestBytes)
+	node, err := h.getHealthyExtraWriteNode(h.requestPool(rawreq, path))
 	if err != nil {
 		logger.Error("failed to get healthy extra write node", zap.Error(err))
 		return internalServerError
 	}
-	url := node.ExtraWrite
-	if path != "" {
-		url = fmt.Sprintf("%s/%s", strings.TrimRight(url, "/"), path)
+	url := nodeUrl(node.ExtraWrite, path)
+	logger = logger.With(zap.String("url", upstreamauth.RedactUrl(url)))
+	return h.forwardHttpRequest(c, []forwardTarget{{node: node, url: url, breaker: node.ExtraWriteBreaker}}, rawreq.Body, nil, logger)
+}
+
+func (h *RpcHandler) bindJsonRpcBody(body []byte) (*jsonrpc.JsonRpcRequest, *jsonrpc.JsonRpcErr) {
+	req := jsonrpc.JsonRpcRequest{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, jsonrpc.ParseError
 	}
-	logger = logger.With(zap.String("url", url))
-	fmt.Println("url", url)
-	return h.forwardHttpRequest(c, url, logger)
+	if !req.IsBatchCall() {
+		if req.GetSingleCall() == nil {
+			return nil, jsonrpc.ParseError
+		}
+		if err := h.chain.ValidateCall(req.GetSingleCall()); err != nil {
+			return nil, err
+		}
+		return &req, nil
+	}
+	if len(req.GetBatchCall()) == 0 {
+		return nil, jsonrpc.ParseError
+	}
+	for _, call := range req.GetBatchCall() {
+		if err := h.chain.ValidateCall(&call); err != nil {
+			return nil, err
+		}
+	}
+	return &req, nil
+}
+
+// responseSanitizer returns nil when no call of req has its response rewritten
+func (h *RpcHandler) responseSanitizer(req *jsonrpc.JsonRpcRequest) func(resp []byte) ([]byte, error) {
+	if !req.IsBatchCall() {
+		method := req.GetSingleCall().Method
+		if !h.sanitizer.Matches(method) {
+			return nil
+		}
+		return func(resp []byte) ([]byte, error) {
+			return h.sanitizer.Apply(method, resp)
+		}
+	}
+	for _, call := range req.GetBatchCall() {
+		if h.sanitizer.Matches(call.Method) {
+			return func(resp []byte) ([]byte, error) {
+				return h.sanitizer.ApplyBatch(req.GetBatchCall(), resp)
+			}
+		}
+	}
+	return nil
+}
+
+// setRequestTimeout bounds the context of the client request by the request timeout of the chain, the returned
+// func releases it
+func (h *RpcHandler) setRequestTimeout(c echo.Context) context.CancelFunc {
+	if h.config.HttpClient.RequestTimeout <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), h.config.HttpClient.RequestTimeout)
+	c.SetRequest(c.Request().WithContext(ctx))
+	return cancel
 }
 
 func (h *RpcHandler) Http(c echo.Context) error {
+	defer h.setRequestTimeout(c)()
 	rawreq := c.Request()
 	path := strings.TrimLeft(strings.TrimPrefix(rawreq.URL.Path, "/rpc/"+h.config.ChainName+"/"+h.app.RpcConfig.ApiKey), "/")
 	logger := h.logger.With(zap.String("id", rawreq.Context().Value("request_id").(string)))
-	node, err := h.getHealthyNode()
+	pool := h.requestPool(rawreq, path)
+	node, err := h.selectNode(pool, c.RealIP())
 	if err != nil {
 		logger.Error("failed to get healthy node", zap.Error(err))
 		return internalServerError
 	}
-	url := node.Http
-	if path != "" {
-		url = fmt.Sprintf("%s/%s", strings.TrimRight(node.Http, "/"), path)
+	url := nodeUrl(node.Http, path)
+	logger = logger.With(zap.String("url", upstreamauth.RedactUrl(url)))
+
+	rawreq.Body = http.MaxBytesReader(c.Response(), rawreq.Body, h.config.MaxRequestBytes)
+	var body io.Reader = rawreq.Body
+	var sanitizeResp func(resp []byte) ([]byte, error)
+	readOnly := rawreq.Method == http.MethodGet || rawreq.Method == http.MethodHead
+	if h.chain.IsJsonRpc() && rawreq.Method == http.MethodPost {
+		rawbody, err := io.ReadAll(rawreq.Body)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return c.JSON(http.StatusOK, jsonrpc.RequestTooLargeErr)
+		}
+		if err != nil {
+			logger.Error("failed to read request body", zap.Error(err))
+			return internalServerError
+		}
+		jsonReq, vErr := h.bindJsonRpcBody(rawbody)
+		if vErr != nil {
+			return c.JSON(http.StatusOK, vErr)
+		}
+		if path == "" && !jsonReq.IsBatchCall() {
+			if ok, err := h.serveBroadcast(c, jsonReq.GetSingleCall(), logger); ok {
+				return err
+			}
+			if ok, err := h.serveQuorum(c, jsonReq.GetSingleCall(), pool, logger); ok {
+				return err
+			}
+		}
+		if path == "" && h.config.PinLatest {
+			pinned, answered, err := h.pinLatest(c, jsonReq, node)
+			if answered || err != nil {
+				return err
+			}
+			if pinned != nil {
+				rawbody = pinned
+			}
+		}
+		body = bytes.NewReader(rawbody)
+		sanitizeResp = h.responseSanitizer(jsonReq)
+		readOnly = !jsonReq.HasWriteCall(h.config.BroadcastMethods)
+	}
+
+	targets := []forwardTarget{{node: node, url: url, breaker: node.HttpBreaker}}
+	if h.config.Hedge && readOnly {
+		if second := h.hedgeNode(pool, node); second != nil {
+			targets = append(targets, forwardTarget{node: second, url: nodeUrl(second.Http, path), breaker: second.HttpBreaker})
+		}
 	}
-	logger = logger.With(zap.String("url", url))
-	fmt.Println("url", url)
-	return h.forwardHttpRequest(c, url, logger)
+	return h.forwardHttpRequest(c, targets, body, sanitizeResp, logger)
 }
 
-func (h *RpcHandler) forwardHttpRequest(c echo.Context, url string, logger *zap.Logger) error {
-	rawreq := c.Request()
-	req, err := http.NewRequest(rawreq.Method, url, rawreq.Body)
+// forwardTarget is a node and the url of the request on it
+type forwardTarget struct {
+	node    *rpcNode
+	url     string
+	breaker *breaker.Breaker // of the endpoint
+}
+
+func nodeUrl(base, path string) string {
+	if path == "" {
+		return base
+	}
+	return fmt.Sprintf("%s/%s", strings.TrimRight(base, "/"), path)
+}
+
+// newUpstreamRequest copies the client request for the target without the hop-by-hop headers
+func (h *RpcHandler) newUpstreamRequest(ctx context.Context, rawreq *http.Request, clientIP string, target forwardTarget, body io.Reader, sanitize bool) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, rawreq.Method, target.url, body)
 	if err != nil {
-		logger.Error("failed to create request", zap.Error(err))
-		return internalServerError
+		return nil, err
 	}
 
 	req.Header = rawreq.Header.Clone()
@@ -178,10 +398,6 @@ func (h *RpcHandler) forwardHttpRequest(c echo.Context, url string, logger *zap.
 		req.Header.Del(header)
 	}
 
-	clientIP, _, err := net.SplitHostPort(rawreq.RemoteAddr)
-	if err != nil {
-		return err
-	}
 	req.Header.Set("X-Real-IP", clientIP)
 	// Append to X-Forwarded-For if it already exists
 	if prior, ok := rawreq.Header["X-Forwarded-For"]; ok && len(prior) > 0 {
@@ -189,8 +405,59 @@ func (h *RpcHandler) forwardHttpRequest(c echo.Context, url string, logger *zap.
 	} else {
 		req.Header.Set("X-Forwarded-For", clientIP)
 	}
+	chaintype.SetNodeAuth(req.Header, &target.node.RpcNode)
+	if sanitize {
+		// let the transport handle compression, the response body is parsed and compressed again for the client
+		req.Header.Del("Accept-Encoding")
+	}
+	// otherwise the client Accept-Encoding goes upstream and a compressed response is passed through as it is
+	return req, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// forwardHttpRequest streams the upstream response to the client, unless sanitizeResp is set to rewrite it.
+// A second target gets the request too when the first has not answered within the hedge delay.
+func (h *RpcHandler) forwardHttpRequest(c echo.Context, targets []forwardTarget, body io.Reader, sanitizeResp func(resp []byte) ([]byte, error), logger *zap.Logger) error {
+	rawreq := c.Request()
+	clientIP, _, err := net.SplitHostPort(rawreq.RemoteAddr)
+	if err != nil {
+		return err
+	}
+
+	send := func(target forwardTarget, body io.Reader) func(ctx context.Context) (*http.Response, error) {
+		return func(ctx context.Context) (*http.Response, error) {
+			req, err := h.newUpstreamRequest(ctx, rawreq, clientIP, target, body, sanitizeResp != nil)
+			if err != nil {
+				return nil, err
+			}
+			start := time.Now()
+			resp, err := h.chain.HttpClient.Do(req)
+			if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+				target.breaker.Record(fmt.Errorf("status %d", resp.StatusCode), time.Since(start))
+			} else {
+				target.breaker.Record(err, time.Since(start))
+			}
+			if err != nil {
+				return nil, upstreamauth.RedactError(err)
+			}
+			h.latency.Observe(time.Since(start))
+			return resp, nil
+		}
+	}
+	var resp *http.Response
+	if len(targets) == 1 {
+		resp, err = send(targets[0], body)(rawreq.Context())
+	} else {
+		var rawbody []byte
+		if rawbody, err = io.ReadAll(body); err != nil {
+			logger.Error("failed to read request body", zap.Error(err))
+			return internalServerError
+		}
+		resp, err = hedge.Do(rawreq.Context(), h.latency.Delay(),
+			send(targets[0], bytes.NewReader(rawbody)),
+			send(targets[1], bytes.NewReader(rawbody)),
+			func(resp *http.Response) { resp.Body.Close() },
+		)
+	}
 	if err != nil {
 		logger.Error("failed to do request", zap.Error(err))
 		return internalServerError
@@ -202,6 +469,20 @@ func (h *RpcHandler) forwardHttpRequest(c echo.Context, url string, logger *zap.
 
 	defer resp.Body.Close()
 
+	if sanitizeResp != nil {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			logger.Error("failed to read response", zap.Error(err))
+			return internalServerError
+		}
+		if respBody, err = sanitizeResp(respBody); err != nil {
+			logger.Error("failed to sanitize response", zap.Error(err))
+			return internalServerError
+		}
+		resp.Header.Del("Content-Length")
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	}
+
 	// Copy headers from response to client
 	for k, v := range resp.Header {
 		c.Response().Header().Set(k, strings.Join(v, ","))
@@ -237,146 +518,190 @@ func (h *RpcHandler) Ws(c echo.Context) error {
 	defer ws.Close()
 
 	// Connect to the upstream WebSocket server
-	upstream, err := dialWs(node.Ws, nil)
+	upstream, err := utils.DialWs(h.chain.WsDialer, node.Ws, chaintype.NodeWsHeader(&node.RpcNode))
 	if err != nil {
-		logger.Error("failed to dial upstream websocket", zap.Error(err), zap.String("url", node.Ws))
+		logger.Error("failed to dial upstream websocket", zap.Error(err), zap.String("url", upstreamauth.RedactUrl(node.Ws)))
 		return internalServerError
 	}
 	defer upstream.Close()
 
-	rawClientConn := ws.UnderlyingConn()
-	rawUpstreamConn := upstream.UnderlyingConn()
+	// a raw relay can not take a close frame in between the relayed frames, it is only closed at the deadline
+	var goAway func()
+	if h.chain.IsJsonRpc() {
+		goAway = func() { app.GoAway(ws) }
+	}
+	done, ok := h.app.Websockets.Track(goAway, func() {
+		ws.Close()
+		upstream.Close()
+	})
+	if !ok {
+		app.GoAway(ws)
+		return nil
+	}
+	defer done()
+
+	if !h.chain.IsJsonRpc() {
+		rawClientConn := ws.UnderlyingConn()
+		rawUpstreamConn := upstream.UnderlyingConn()
 
-	go io.Copy(rawClientConn, rawUpstreamConn)
-	io.Copy(rawUpstreamConn, rawClientConn)
+		go io.Copy(rawClientConn, rawUpstreamConn)
+		io.Copy(rawUpstreamConn, rawClientConn)
+		return nil
+	}
 
+	h.relayWsMessages(ws, upstream, logger)
 	return nil
 }
 
-func (h *RpcHandler) checkNodesHealthy() {
-	switch h.config.ChainType {
-	case "tron":
-		h.checkTronNodesHealthy()
-	default:
-		h.checkHttpAndWsNodesHealthy()
+// relayWsMessages relays json-rpc messages between client and upstream, calls rejected by the
+// chain type are answered directly and never reach the upstream
+func (h *RpcHandler) relayWsMessages(client, upstream *websocket.Conn, logger *zap.Logger) {
+	var clientLock sync.Mutex
+	writeClient := func(messageType int, data []byte) error {
+		clientLock.Lock()
+		defer clientLock.Unlock()
+		return client.WriteMessage(messageType, data)
+	}
+
+	pending := &wsPendingCalls{methods: map[string]string{}}
+	go func() {
+		defer client.Close()
+		for {
+			messageType, message, err := upstream.ReadMessage()
+			if err != nil {
+				return
+			}
+			if h.sanitizer != nil {
+				if message, err = h.sanitizeWsResponse(pending, message); err != nil {
+					logger.Error("failed to sanitize response", zap.Error(err))
+					message, _ = json.Marshal(jsonrpc.NewInternalServerError(nil))
+				}
+			}
+			if err := writeClient(messageType, message); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		messageType, message, err := client.ReadMessage()
+		if err != nil {
+			return
+		}
+		// binary frames carry json-rpc too, they are checked like text frames
+		req, vErr := h.bindJsonRpcBody(message)
+		if vErr != nil {
+			resp, _ := json.Marshal(vErr)
+			if err := writeClient(websocket.TextMessage, resp); err != nil {
+				return
+			}
+			continue
+		}
+		pending.add(h.sanitizer, req)
+		if err := upstream.WriteMessage(messageType, message); err != nil {
+			logger.Error("failed to write upstream websocket", zap.Error(err))
+			return
+		}
 	}
 }
 
-func (h *RpcHandler) reportNodeErrors() {
-	if prometheus.PushgatewayBase == "" {
-		return
+// wsPendingCalls remembers the methods of websocket calls whose responses are sanitized, keyed by call id
+type wsPendingCalls struct {
+	sync.Mutex
+	methods map[string]string
+}
+
+func (p *wsPendingCalls) add(sanitizer *sanitize.Pipeline, req *jsonrpc.JsonRpcRequest) {
+	calls := req.GetBatchCall()
+	if !req.IsBatchCall() {
+		calls = []jsonrpc.JsonRpcSingleRequest{*req.GetSingleCall()}
 	}
-	metrics := make([]prometheus.ErrorNumMetric, 0, len(h.nodes))
-	for i, node := range h.nodes {
-		nodeName := node.Name
-		if nodeName == "" {
-			nodeName = fmt.Sprintf("index_%d", i)
-		}
-		count := h.nodeErrorCounts[i]
-		if count > 0 {
-			metrics = append(metrics, prometheus.ErrorNumMetric{
-				NodeName: nodeName,
-				ErrorNum: count,
-			})
+	p.Lock()
+	defer p.Unlock()
+	for _, call := range calls {
+		if sanitizer.Matches(call.Method) {
+			id, _ := json.Marshal(call.ID)
+			p.methods[string(id)] = call.Method
 		}
 	}
-	if len(metrics) > 0 {
-		prometheus.PushMetrics(h.config.ChainName, metrics)
+}
+
+// take returns and forgets the method of the call answered by resp
+func (p *wsPendingCalls) take(resp []byte) string {
+	var header struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(resp, &header); err != nil || header.ID == nil {
+		return ""
 	}
+	p.Lock()
+	defer p.Unlock()
+	method := p.methods[string(header.ID)]
+	delete(p.methods, string(header.ID))
+	return method
 }
 
-func (h *RpcHandler) checkTronNodesHealthy() {
-	httpBlockNumbers := make([]int64, len(h.nodes))
-	extraWriteBlockNumbers := make([]int64, len(h.nodes))
-	for i, node := range h.nodes {
-		if node.Http != "" {
-			url := node.Http
-			content := `{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}`
-			httpBlockNumber, err := getBlockNumberFromHttpJsonRpc(url, content, h.jqQuery)
-			if err != nil {
-				log.Printf("failed to get block number from http %s: %v", url, err)
-				continue
-			}
-			httpBlockNumbers[i] = int64(httpBlockNumber)
+func (h *RpcHandler) sanitizeWsResponse(pending *wsPendingCalls, message []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(message)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		if method := pending.take(message); method != "" {
+			return h.sanitizer.Apply(method, message)
 		}
+		return message, nil
+	}
 
-		// check extra write api
-		if node.ExtraWrite != "" {
-			url := node.ExtraWrite + "/getnowblock"
-			req, err := http.NewRequest("POST", url, nil)
-			if err != nil {
-				log.Printf("failed to get block number from extra write api %s: %v", url, err)
-				continue
-			}
-			extraWriteApiBlockNumber, err := getBlockNumberFromHttp(req, h.tronExtraWriteJqQuery)
+	var items []json.RawMessage
+	if err := json.Unmarshal(trimmed, &items); err != nil {
+		return message, nil
+	}
+	changed := false
+	for i, item := range items {
+		if method := pending.take(item); method != "" {
+			sanitized, err := h.sanitizer.Apply(method, item)
 			if err != nil {
-				log.Printf("failed to get block number from extra write api %s: %v", url, err)
-				continue
+				return nil, err
 			}
-			extraWriteBlockNumbers[i] = int64(extraWriteApiBlockNumber)
+			items[i] = sanitized
+			changed = true
 		}
 	}
-	maxBlockNumber := lo.Max(append(httpBlockNumbers, extraWriteBlockNumbers...))
-
-	for i, node := range h.nodes {
-		httpHealthy := httpBlockNumbers[i] >= maxBlockNumber-h.config.MaxBehindBlocks
-		extraWriteHealthy := extraWriteBlockNumbers[i] >= maxBlockNumber-h.config.MaxBehindBlocks
-		node.HttpHealth.Store(httpHealthy)
-		node.ExtraWriteHealth.Store(extraWriteHealthy)
-
-		unhealthy := false
-		if node.Http != "" && !httpHealthy {
-			unhealthy = true
-		}
-		if node.ExtraWrite != "" && !extraWriteHealthy {
-			unhealthy = true
-		}
-		if unhealthy {
-			h.nodeErrorCounts[i]++
-		}
+	if !changed {
+		return message, nil
 	}
+	return json.Marshal(items)
 }
 
-func (h *RpcHandler) checkHttpAndWsNodesHealthy() {
+func (h *RpcHandler) checkNodesHealthy() {
 	httpBlockNumbers := make([]int64, len(h.nodes))
 	wsBlockNumbers := make([]int64, len(h.nodes))
+	extraWriteBlockNumbers := make([]int64, len(h.nodes))
 	for i, node := range h.nodes {
-		var httpBlockNumber uint64
-		var err error
-		if h.config.ChainType == "evm" || h.config.ChainType == "svm" {
-			content := fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":1}`, h.config.BlockNumberMethod)
-			httpBlockNumber, err = getBlockNumberFromHttpJsonRpc(node.Http, content, h.jqQuery)
-			if err != nil {
-				log.Printf("failed to get block number from http %s: %v", node.Http, err)
-				continue
-			}
-		} else if h.config.ChainType == "aptos" {
-			url := node.Http + "/v1"
-			httpBlockNumber, err = getBlockNumberFromAptosHttp(url, h.jqQuery)
+		// check extra write api
+		if node.ExtraWrite != "" {
+			extraWriteBlockNumber, err := h.chain.ProbeExtraWrite(&node.RpcNode)
 			if err != nil {
-				log.Printf("failed to get block number from http %s: %v", url, err)
-				continue
+				log.Printf("failed to get block number from extra write api %s: %v", node.ExtraWrite, err)
+			} else {
+				extraWriteBlockNumbers[i] = int64(extraWriteBlockNumber)
 			}
 		}
+
+		if node.Http == "" {
+			continue
+		}
+		httpBlockNumber, err := h.chain.ProbeHttp(&node.RpcNode)
+		if err != nil {
+			log.Printf("failed to get block number from http %s: %v", node.Http, err)
+			continue
+		}
 		httpBlockNumbers[i] = int64(httpBlockNumber)
+		node.BlockNumber.Store(httpBlockNumber)
 
 		if node.Ws == "" {
 			continue
 		}
 
-		var wsBlockNumber uint64
-		if h.config.ChainType == "evm" {
-			content := fmt.Sprintf(`{"jsonrpc":"2.0","method":"%s","params":[],"id":1}`, h.config.BlockNumberMethod)
-			wsBlockNumber, err = getBlockNumberFromEvmWs(node.Ws, content, h.jqQuery)
-		} else if h.config.ChainType == "svm" {
-			var query *gojq.Query
-			query, err = gojq.Parse(".params.result.slot")
-			if err != nil {
-				log.Printf("failed to parse block number result expression: %v", err)
-				continue
-			}
-			wsBlockNumber, err = getBlockNumberFromSvmWs(node.Ws, query)
-		}
+		wsBlockNumber, err := h.chain.ProbeWs(&node.RpcNode)
 		if err != nil {
 			log.Printf("failed to get block number from ws %s: %v", node.Ws, err)
 			continue
@@ -387,13 +712,21 @@ func (h *RpcHandler) checkHttpAndWsNodesHealthy() {
 		}
 		wsBlockNumbers[i] = int64(wsBlockNumber)
 	}
-	maxBlockNumber := lo.Max(append(httpBlockNumbers, wsBlockNumbers...))
+	maxBlockNumber := lo.Max(append(append(httpBlockNumbers, wsBlockNumbers...), extraWriteBlockNumbers...))
+	if h.headTracker != nil {
+		maxBlockNumber = max(maxBlockNumber, int64(h.headTracker.Latest()))
+	}
 
 	for i, node := range h.nodes {
 		httpHealthy := httpBlockNumbers[i] >= maxBlockNumber-h.config.MaxBehindBlocks
 		wsHealthy := wsBlockNumbers[i] >= maxBlockNumber-h.config.MaxBehindBlocks
+		extraWriteHealthy := extraWriteBlockNumbers[i] >= maxBlockNumber-h.config.MaxBehindBlocks
 		node.HttpHealth.Store(httpHealthy)
 		node.WsHealth.Store(wsHealthy)
+		if httpBlockNumbers[i] > 0 {
+			node.BlockLag.Store(uint64(max(maxBlockNumber-httpBlockNumbers[i], 0)))
+		}
+		node.ExtraWriteHealth.Store(extraWriteHealthy)
 
 		unhealthy := false
 		if node.Http != "" && !httpHealthy {
@@ -402,157 +735,56 @@ func (h *RpcHandler) checkHttpAndWsNodesHealthy() {
 		if node.Ws != "" && !wsHealthy {
 			unhealthy = true
 		}
+		if node.ExtraWrite != "" && !extraWriteHealthy {
+			unhealthy = true
+		}
 		if unhealthy {
 			h.nodeErrorCounts[i]++
 		}
 	}
 }
 
-func dialWs(urlStr string, requestHeader http.Header) (*websocket.Conn, error) {
-	upstream, resp, err := websocket.DefaultDialer.Dial(urlStr, requestHeader)
-	if err != nil {
-		respText := "<nil>"
-		if resp != nil {
-			if resp.Body == nil {
-				respText = fmt.Sprintf("%d body: <nil>", resp.StatusCode)
-			} else {
-				if body, err := io.ReadAll(resp.Body); err == nil {
-					respText = fmt.Sprintf("%d body: %s", resp.StatusCode, string(body))
-				} else {
-					respText = fmt.Sprintf("%d body: <nil>: read resp body error: %v", resp.StatusCode, err)
-				}
-			}
-		}
-		return nil, fmt.Errorf("failed to dial websocket: %w\nresponse: %s", err, respText)
-	}
-
-	return upstream, nil
-}
-
-func getBlockNumberFromEvmWs(url string, content string, jqQuery *gojq.Query) (uint64, error) {
-	upstream, err := dialWs(url, nil)
-	if err != nil {
-		return 0, err
-	}
-	defer upstream.Close()
-	if err = upstream.WriteMessage(websocket.TextMessage, []byte(content)); err != nil {
-		return 0, err
+func (h *RpcHandler) reportNodeErrors() {
+	if prometheus.PushgatewayBase == "" {
+		return
 	}
-
-	for {
-		messageType, message, err := upstream.ReadMessage()
-		if err != nil {
-			return 0, err
-		}
-
-		if messageType != websocket.TextMessage {
-			continue
-		}
-		result, err := utils.JqQueryFirst(message, jqQuery)
-		if err != nil {
-			return 0, err
-		}
-		blockNumber, err := utils.ToUint64(result)
-		if err != nil {
-			return 0, err
+	metrics := make([]prometheus.ErrorNumMetric, 0, len(h.nodes))
+	for i, node := range h.nodes {
+		nodeName := node.Name
+		if nodeName == "" {
+			nodeName = fmt.Sprintf("index_%d", i)
 		}
-		if blockNumber == 0 {
-			return 0, fmt.Errorf("main WS blockNumber is 0")
+		count := h.nodeErrorCounts[i]
+		if count > 0 {
+			metrics = append(metrics, prometheus.ErrorNumMetric{
+				NodeName: nodeName,
+				ErrorNum: count,
+			})
 		}
-		return blockNumber, nil
 	}
-}
-
-func getBlockNumberFromSvmWs(url string, jqQuery *gojq.Query) (uint64, error) {
-	upstream, err := dialWs(url, nil)
-	if err != nil {
-		return 0, err
+	if len(metrics) > 0 {
+		prometheus.PushMetrics(h.config.ChainName, metrics)
 	}
-	var subscriptionID int64
-	defer func() {
-		unsubscribeMessage := fmt.Sprintf(`{"jsonrpc": "2.0","id": 2,"method": "slotUnsubscribe", "params": [%d]}`, subscriptionID)
-		// fmt.Println("unsubscribeMessage", unsubscribeMessage)
-		upstream.WriteMessage(websocket.TextMessage, []byte(unsubscribeMessage))
-		upstream.Close()
-	}()
+}
 
-	if err = upstream.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0","id": 1,"method": "slotSubscribe"}`)); err != nil {
-		return 0, err
-	}
-	_, message, err := upstream.ReadMessage()
-	if err != nil {
-		return 0, err
-	}
-	var subscription map[string]any
-	if err := json.Unmarshal(message, &subscription); err != nil {
-		return 0, err
+func (h *RpcHandler) reportBreakers() {
+	if prometheus.PushgatewayBase == "" {
+		return
 	}
-	subscriptionID = int64(subscription["result"].(float64))
-
-	for {
-		messageType, message, err := upstream.ReadMessage()
-		if err != nil {
-			return 0, err
-		}
-		if messageType != websocket.TextMessage {
-			continue
-		}
-		result, err := utils.JqQueryFirst(message, jqQuery)
-		if err != nil {
-			return 0, err
+	metrics := make([]prometheus.BreakerMetric, 0, len(h.nodes))
+	for i, node := range h.nodes {
+		nodeName := node.Name
+		if nodeName == "" {
+			nodeName = fmt.Sprintf("index_%d", i)
 		}
-		blockNumber, err := utils.ToUint64(result)
-		if err != nil {
-			return 0, err
+		if node.Http != "" {
+			metrics = append(metrics, prometheus.BreakerMetric{NodeName: nodeName, Endpoint: "http", State: int(node.HttpBreaker.State())})
 		}
-		if blockNumber == 0 {
-			return 0, fmt.Errorf("main WS blockNumber is 0")
+		if node.ExtraWrite != "" {
+			metrics = append(metrics, prometheus.BreakerMetric{NodeName: nodeName, Endpoint: "extra_write", State: int(node.ExtraWriteBreaker.State())})
 		}
-		return blockNumber, nil
-	}
-}
-
-func getBlockNumberFromHttp(req *http.Request, jqQuery *gojq.Query) (uint64, error) {
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	if resp == nil || resp.Body == nil {
-		return 0, fmt.Errorf("response is nil")
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	result, err := utils.JqQueryFirst(body, jqQuery)
-	if err != nil {
-		return 0, err
-	}
-
-	blockNumber, err := utils.ToUint64(result)
-	if err != nil {
-		return 0, err
 	}
-
-	return blockNumber, nil
-}
-
-func getBlockNumberFromHttpJsonRpc(url string, content string, jqQuery *gojq.Query) (uint64, error) {
-	req, err := http.NewRequest("POST", url, io.NopCloser(bytes.NewBufferString(content)))
-	if err != nil {
-		return 0, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	return getBlockNumberFromHttp(req, jqQuery)
-}
-
-func getBlockNumberFromAptosHttp(url string, jqQuery *gojq.Query) (uint64, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, err
+	if len(metrics) > 0 {
+		prometheus.PushBreakerMetrics(h.config.ChainName, metrics)
 	}
-	return getBlockNumberFromHttp(req, jqQuery)
 }
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/headtracker"
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"github.com/labstack/echo/v4"
+)
+
+// stickyNode is the node a client was sent to and until when it keeps it
+type stickyNode struct {
+	node  *rpcNode
+	until time.Time
+}
+
+// selectNode returns a healthy node of the pool for the client, see config.ChainConfig.LoadBalance. The rpc
+// routes share one api key, the client is its ip.
+func (h *RpcHandler) selectNode(pool, client string) (*rpcNode, error) {
+	if h.config.LoadBalance != config.LoadBalanceRoundRobin {
+		return h.getHealthyNode(pool)
+	}
+
+	key := pool + ":" + client
+	if h.config.StickySeconds > 0 {
+		if v, ok := h.sticky.Load(key); ok {
+			sticky := v.(stickyNode)
+			if time.Now().Before(sticky.until) && sticky.node.httpReady() {
+				return sticky.node, nil
+			}
+		}
+	}
+
+	var healthy, open []*rpcNode
+	for _, node := range h.nodes {
+		if !node.inPool(pool) || !node.HttpHealth.Load() {
+			continue
+		}
+		if node.HttpBreaker.Ready() {
+			healthy = append(healthy, node)
+		} else {
+			open = append(open, node)
+		}
+	}
+	if len(healthy) == 0 {
+		// an open breaker only turns calls away when another node can take them
+		healthy = open
+	}
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("no healthy HTTP RPC node found in %s pool", pool)
+	}
+	node := healthy[h.next.Add(1)%uint64(len(healthy))]
+
+	if h.config.StickySeconds > 0 {
+		h.sticky.Store(key, stickyNode{node: node, until: time.Now().Add(time.Second * time.Duration(h.config.StickySeconds))})
+	}
+	return node, nil
+}
+
+// hedgeNode returns a healthy node of the pool other than node, nil when there is none
+func (h *RpcHandler) hedgeNode(pool string, node *rpcNode) *rpcNode {
+	var healthy []*rpcNode
+	for _, n := range h.nodes {
+		if n != node && n.inPool(pool) && n.httpReady() {
+			healthy = append(healthy, n)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return healthy[h.next.Add(1)%uint64(len(healthy))]
+}
+
+// cleanSticky forgets the clients whose window is over
+func (h *RpcHandler) cleanSticky() {
+	now := time.Now()
+	h.sticky.Range(func(key, v any) bool {
+		if now.After(v.(stickyNode).until) {
+			h.sticky.Delete(key)
+		}
+		return true
+	})
+}
+
+// evmHeadTracker returns the tracker of the chain, a new one following the read nodes when no other handler
+// tracks the chain yet
+func (h *RpcHandler) evmHeadTracker() app.HeadTracker {
+	if tracker, ok := h.app.HeadTrackers[h.config.ChainName]; ok {
+		return tracker
+	}
+	var endpoints []headtracker.Endpoint
+	for _, node := range h.config.Nodes {
+		if node.Http == "" || (node.Pool != "" && node.Pool != config.NodePoolRead) {
+			continue
+		}
+		endpoints = append(endpoints, headtracker.Endpoint{Http: node.Http, Ws: node.Ws, Auth: node.Auth})
+	}
+	tracker := headtracker.New(headtracker.Config{
+		ChainName:   h.config.ChainName,
+		Endpoints:   endpoints,
+		HttpClient:  h.chain.HttpClient,
+		PushMetrics: h.app.RpcConfig != nil && h.app.RpcConfig.HealthPushgateway != "",
+	}, h.logger)
+	tracker.Start()
+	if h.app.HeadTrackers != nil {
+		h.app.HeadTrackers[h.config.ChainName] = tracker
+	}
+	return tracker
+}
+
+// consensusHead is the latest block of the head tracker, or the median block number of the healthy nodes
+// without one, 0 when no node has been probed
+func (h *RpcHandler) consensusHead() uint64 {
+	if h.headTracker != nil {
+		if latest := h.headTracker.Latest(); latest > 0 {
+			return latest
+		}
+	}
+	var numbers []uint64
+	for _, node := range h.nodes {
+		if number := node.BlockNumber.Load(); number > 0 && node.HttpHealth.Load() {
+			numbers = append(numbers, number)
+		}
+	}
+	if len(numbers) == 0 {
+		return 0
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers[(len(numbers)-1)/2]
+}
+
+// pinnedBlock is the consensus head less the lag of the node. BlockNumber of the node is only refreshed by the
+// health check, the lag follows the live head in between.
+func (h *RpcHandler) pinnedBlock(node *rpcNode) uint64 {
+	head := h.consensusHead()
+	if lag := node.BlockLag.Load(); lag < head {
+		return head - lag
+	}
+	return head
+}
+
+// pinLatest rewrites the latest block params of the calls to the pinned block of the node, it answers
+// eth_blockNumber itself and returns true when it did
+func (h *RpcHandler) pinLatest(c echo.Context, req *jsonrpc.JsonRpcRequest, node *rpcNode) (rawbody []byte, answered bool, err error) {
+	number := h.pinnedBlock(node)
+	if number == 0 {
+		return nil, false, nil
+	}
+
+	if !req.IsBatchCall() {
+		call := req.GetSingleCall()
+		if call.Method == "eth_blockNumber" {
+			result, _ := json.Marshal(fmt.Sprintf("0x%x", number))
+			return nil, true, c.JSON(http.StatusOK, jsonrpc.JsonRpcResponse{ID: call.ID, JsonRpcVersion: "2.0", Result: result})
+		}
+		if !jsonrpc.PinEvmLatest(call, number) {
+			return nil, false, nil
+		}
+	} else {
+		changed := false
+		calls := req.GetBatchCall()
+		for i := range calls {
+			changed = jsonrpc.PinEvmLatest(&calls[i], number) || changed
+		}
+		if !changed {
+			return nil, false, nil
+		}
+	}
+
+	rawbody, err = json.Marshal(req)
+	return rawbody, false, err
+}
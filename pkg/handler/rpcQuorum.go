@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/chaintype"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/prometheus"
+	"starnet/chain-api/pkg/upstreamauth"
+	"starnet/chain-api/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const defaultQuorumNodes = 3
+
+// quorumAnswer is the response of one node, key compares results independent of formatting and key order
+type quorumAnswer struct {
+	node string
+	resp *jsonrpc.JsonRpcResponse
+	key  string
+}
+
+// quorumRule returns the rule of the method, nil when calls to it are sent to a single node
+func (h *RpcHandler) quorumRule(method string) *config.QuorumRule {
+	for i := range h.config.Quorum {
+		if utils.In(method, h.config.Quorum[i].Methods) {
+			return &h.config.Quorum[i]
+		}
+	}
+	return nil
+}
+
+// quorumNodes returns up to n healthy nodes of the pool, starting at the next round robin node
+func (h *RpcHandler) quorumNodes(pool string, n int) []*rpcNode {
+	var healthy []*rpcNode
+	for _, node := range h.nodes {
+		if node.inPool(pool) && node.httpReady() {
+			healthy = append(healthy, node)
+		}
+	}
+	if len(healthy) <= n {
+		return healthy
+	}
+	start := int(h.next.Add(1) % uint64(len(healthy)))
+	nodes := make([]*rpcNode, n)
+	for i := range nodes {
+		nodes[i] = healthy[(start+i)%len(healthy)]
+	}
+	return nodes
+}
+
+// serveQuorum answers calls of quorum methods with the majority result of several nodes, it returns false
+// for other calls and when less than two nodes are healthy
+func (h *RpcHandler) serveQuorum(c echo.Context, call *jsonrpc.JsonRpcSingleRequest, pool string, logger *zap.Logger) (bool, error) {
+	rule := h.quorumRule(call.Method)
+	if rule == nil {
+		return false, nil
+	}
+	n := rule.Nodes
+	if n == 0 {
+		n = defaultQuorumNodes
+	}
+	nodes := h.quorumNodes(pool, n)
+	if len(nodes) < 2 {
+		logger.Warn("not enough healthy nodes for quorum", zap.String("method", call.Method), zap.Int("nodes", len(nodes)))
+		return false, nil
+	}
+	if h.config.PinLatest {
+		// nodes at different heights would disagree on latest
+		if head := h.consensusHead(); head > 0 {
+			jsonrpc.PinEvmLatest(call, head)
+		}
+	}
+	body, err := json.Marshal(call)
+	if err != nil {
+		return true, err
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), time.Second*10)
+	defer cancel()
+	answers := make([]*quorumAnswer, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node *rpcNode) {
+			defer wg.Done()
+			answer, err := h.quorumCall(ctx, node, body)
+			if err != nil {
+				logger.Warn("quorum call failed", zap.String("node", node.Name), zap.Error(err))
+				return
+			}
+			answers[i] = answer
+		}(i, node)
+	}
+	wg.Wait()
+
+	resp, agreed := quorumResult(answers, len(nodes))
+	if !agreed {
+		h.recordQuorumMismatch(call.Method, answers, logger)
+	}
+	if resp == nil {
+		return true, c.JSON(http.StatusOK, &jsonrpc.JsonRpcErr{ID: call.ID, Code: -32000, Message: "nodes did not reach a quorum"})
+	}
+	resp.ID = call.ID
+	return true, c.JSON(http.StatusOK, resp)
+}
+
+// quorumCall sends a json-rpc call to a node
+func (h *RpcHandler) quorumCall(ctx context.Context, node *rpcNode, body []byte) (*quorumAnswer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, node.Http, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	chaintype.SetNodeAuth(req.Header, &node.RpcNode)
+	start := time.Now()
+	res, err := h.chain.HttpClient.Do(req)
+	if err == nil && res.StatusCode >= http.StatusInternalServerError {
+		node.HttpBreaker.Record(fmt.Errorf("status %d", res.StatusCode), time.Since(start))
+	} else {
+		node.HttpBreaker.Record(err, time.Since(start))
+	}
+	if err != nil {
+		return nil, upstreamauth.RedactError(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		// the body of a failed call is often an html or plain text error page of a gateway
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	respBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &jsonrpc.JsonRpcResponse{}
+	if err = json.Unmarshal(respBody, resp); err != nil {
+		return nil, err
+	}
+	answer := &quorumAnswer{node: node.Name, resp: resp}
+	if len(resp.Error) > 0 {
+		answer.key = "error:" + canonicalJson(resp.Error)
+	} else {
+		answer.key = canonicalJson(resp.Result)
+	}
+	return answer, nil
+}
+
+// canonicalJson re-encodes a json value, which sorts the object keys and drops the whitespace
+func canonicalJson(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return string(raw)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// quorumResult returns the response of more than half of the queried nodes, nil when there is no majority.
+// agreed is false when some answers differ.
+func quorumResult(answers []*quorumAnswer, queried int) (resp *jsonrpc.JsonRpcResponse, agreed bool) {
+	counts := make(map[string]int)
+	var best *quorumAnswer
+	for _, answer := range compactAnswers(answers) {
+		counts[answer.key]++
+		if best == nil || counts[answer.key] > counts[best.key] {
+			best = answer
+		}
+	}
+	if best == nil || counts[best.key]*2 <= queried {
+		return nil, false
+	}
+	return best.resp, len(counts) == 1
+}
+
+func compactAnswers(answers []*quorumAnswer) []*quorumAnswer {
+	var compact []*quorumAnswer
+	for _, answer := range answers {
+		if answer != nil {
+			compact = append(compact, answer)
+		}
+	}
+	return compact
+}
+
+// recordQuorumMismatch logs the answers of the nodes and counts the mismatch for the metrics
+func (h *RpcHandler) recordQuorumMismatch(method string, answers []*quorumAnswer, logger *zap.Logger) {
+	fields := []zap.Field{zap.String("method", method)}
+	for _, answer := range compactAnswers(answers) {
+		fields = append(fields, zap.String(answer.node, answer.key))
+	}
+	logger.Warn("quorum nodes disagree", fields...)
+
+	counter, _ := h.quorumMismatches.LoadOrStore(method, new(atomic.Int64))
+	counter.(*atomic.Int64).Add(1)
+}
+
+func (h *RpcHandler) reportQuorumMismatches() {
+	if prometheus.PushgatewayBase == "" {
+		return
+	}
+	var metrics []prometheus.QuorumMetric
+	h.quorumMismatches.Range(func(method, counter any) bool {
+		metrics = append(metrics, prometheus.QuorumMetric{Method: method.(string), Mismatches: counter.(*atomic.Int64).Load()})
+		return true
+	})
+	if len(metrics) > 0 {
+		prometheus.PushQuorumMetrics(h.config.ChainName, metrics)
+	}
+}
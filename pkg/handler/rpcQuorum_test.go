@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"starnet/chain-api/pkg/jsonrpc"
+)
+
+func testAnswer(node, result, rpcErr string) *quorumAnswer {
+	resp := &jsonrpc.JsonRpcResponse{JsonRpcVersion: "2.0"}
+	answer := &quorumAnswer{node: node, resp: resp}
+	if rpcErr != "" {
+		resp.Error = json.RawMessage(rpcErr)
+		answer.key = "error:" + canonicalJson(resp.Error)
+	} else {
+		resp.Result = json.RawMessage(result)
+		answer.key = canonicalJson(resp.Result)
+	}
+	return answer
+}
+
+func TestCanonicalJson(t *testing.T) {
+	if a, b := canonicalJson(json.RawMessage(`{"b": 1, "a": [1, 2]}`)), canonicalJson(json.RawMessage(`{"a":[1,2],"b":1}`)); a != b {
+		t.Errorf("expected equal keys, got %s and %s", a, b)
+	}
+	if got := canonicalJson(json.RawMessage(`not json`)); got != "not json" {
+		t.Errorf("expected invalid json to be kept, got %s", got)
+	}
+}
+
+func TestQuorumResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []*quorumAnswer
+		result  string // of the returned response, empty when there is no majority
+		agreed  bool
+	}{
+		{
+			name:    "unanimous",
+			answers: []*quorumAnswer{testAnswer("a", `"0x1"`, ""), testAnswer("b", `"0x1"`, ""), testAnswer("c", `"0x1"`, "")},
+			result:  `"0x1"`,
+			agreed:  true,
+		},
+		{
+			name:    "majority",
+			answers: []*quorumAnswer{testAnswer("a", `{"x":1,"y":2}`, ""), testAnswer("b", `"0x2"`, ""), testAnswer("c", `{"y":2,"x":1}`, "")},
+			result:  `{"x":1,"y":2}`,
+		},
+		{
+			name:    "tie",
+			answers: []*quorumAnswer{testAnswer("a", `"0x1"`, ""), testAnswer("b", `"0x2"`, "")},
+		},
+		{
+			name:    "all failed",
+			answers: []*quorumAnswer{nil, nil, nil},
+		},
+		{
+			// a failed call counts against the majority, two of four is not more than half
+			name:    "failed and rpc error",
+			answers: []*quorumAnswer{testAnswer("a", `"0x1"`, ""), nil, testAnswer("c", `"0x1"`, ""), testAnswer("d", "", `{"code":-32000,"message":"header not found"}`)},
+		},
+		{
+			name:    "failed call with majority",
+			answers: []*quorumAnswer{testAnswer("a", `"0x1"`, ""), nil, testAnswer("c", `"0x1"`, "")},
+			result:  `"0x1"`,
+			agreed:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, agreed := quorumResult(tt.answers, len(tt.answers))
+			if tt.result == "" {
+				if resp != nil {
+					t.Fatalf("expected no majority, got %s", resp.Result)
+				}
+			} else if resp == nil || string(resp.Result) != tt.result {
+				t.Fatalf("expected result %s, got %v", tt.result, resp)
+			}
+			if agreed != tt.agreed {
+				t.Errorf("expected agreed %t, got %t", tt.agreed, agreed)
+			}
+		})
+	}
+}
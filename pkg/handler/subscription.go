@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/proxy"
+	"starnet/chain-api/pkg/subscription"
+)
+
+// SetSubscriptions serves the newHeads, logs and newPendingTransactions subscriptions from the hub instead
+// of the upstream connection of each client
+func (h *JsonRpcHandler) SetSubscriptions(hub *subscription.Hub) {
+	h.subscriptions = hub
+}
+
+// subscribe answers the eth_subscribe and eth_unsubscribe calls served by the hub, subs are the hub
+// subscriptions of the client. start of a new subscription is called once resp has been sent. ok is false for
+// calls sent to the upstream.
+func (h *JsonRpcHandler) subscribe(req *jsonrpc.JsonRpcRequest, subs map[string]bool, client *proxy.Client) (resp interface{}, start func(), ok bool) {
+	if h.subscriptions == nil || req.IsBatchCall() {
+		return nil, nil, false
+	}
+	call := req.GetSingleCall()
+	switch call.Method {
+	case "eth_subscribe":
+		sub, ok, err := h.subscriptions.Subscribe(call.Params)
+		if !ok {
+			return nil, nil, false
+		}
+		if err != nil {
+			return jsonrpc.NewInvalidParamsError(call.ID, err.Error()), nil, true
+		}
+		subs[sub.ID] = true
+		result, _ := json.Marshal(sub.ID)
+		start = func() {
+			sub.Start(func(data []byte) {
+				client.Send(proxy.RespData{Data: data, Subscription: true})
+			})
+		}
+		return jsonrpc.JsonRpcResponse{ID: call.ID, JsonRpcVersion: "2.0", Result: result}, start, true
+	case "eth_unsubscribe":
+		var params []string
+		if err := json.Unmarshal(call.Params, &params); err != nil || len(params) == 0 || !subs[params[0]] {
+			return nil, nil, false
+		}
+		delete(subs, params[0])
+		result, _ := json.Marshal(h.subscriptions.Unsubscribe(params[0]))
+		return jsonrpc.JsonRpcResponse{ID: call.ID, JsonRpcVersion: "2.0", Result: result}, nil, true
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,354 @@
+package headtracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/prometheus"
+	"starnet/chain-api/pkg/upstreamauth"
+	"starnet/chain-api/pkg/utils"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Endpoint is a node the tracker follows, the next endpoint is used when it fails
+type Endpoint struct {
+	Http string
+	Ws   string // newHeads subscription, polling over Http is used when empty
+	Auth config.UpstreamAuth
+}
+
+type Config struct {
+	ChainName    string
+	Endpoints    []Endpoint
+	PollInterval time.Duration // of the latest block without ws and of the safe and finalized blocks, defaults to 12s
+	History      int           // number of recent block hashes kept for reorg detection, defaults to 128
+
+	// FinalityDepth stands in for safe and finalized on chains without those block tags, 0 leaves them unknown
+	FinalityDepth uint64
+
+	HttpClient  *http.Client      // defaults to http.DefaultClient, a call is bounded by PollInterval
+	WsDialer    *websocket.Dialer // of the newHeads subscription, defaults to websocket.DefaultDialer
+	PushMetrics bool              // push the head numbers to the pushgateway every minute
+}
+
+// Head is an evm block header
+type Head struct {
+	Number     uint64
+	Hash       string
+	ParentHash string
+}
+
+// Tracker follows the head of an evm chain
+type Tracker struct {
+	cfg    Config
+	logger *zap.Logger
+
+	mutex     sync.RWMutex
+	latest    Head
+	safe      uint64
+	finalized uint64
+	hashes    map[uint64]string
+	reorgs    int
+	onReorg   []func(number uint64)
+	endpoint  int
+
+	finalityTags bool // the node answers the safe and finalized tags, FinalityDepth is not used
+}
+
+func New(cfg Config, logger *zap.Logger) *Tracker {
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = time.Second * 12
+	}
+	if cfg.History == 0 {
+		cfg.History = 128
+	}
+	if cfg.HttpClient == nil {
+		cfg.HttpClient = http.DefaultClient
+	}
+	return &Tracker{
+		cfg:    cfg,
+		logger: logger.With(zap.String("chain", cfg.ChainName), zap.String("component", "headtracker")),
+		hashes: make(map[uint64]string),
+	}
+}
+
+// Start follows the chain in the background
+func (t *Tracker) Start() {
+	if len(t.cfg.Endpoints) == 0 {
+		return
+	}
+	go t.followHeads()
+	go t.pollFinality()
+	if t.cfg.PushMetrics {
+		go t.reportMetrics()
+	}
+}
+
+// Latest returns 0 until the first head is known
+func (t *Tracker) Latest() uint64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.latest.Number
+}
+
+func (t *Tracker) Safe() uint64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.safe
+}
+
+func (t *Tracker) Finalized() uint64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.finalized
+}
+
+// Hash returns the hash of a recent block on the current chain
+func (t *Tracker) Hash(number uint64) (string, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	hash, ok := t.hashes[number]
+	return hash, ok
+}
+
+// OnReorg registers fn, it is called with the first replaced block number when a reorg is detected
+func (t *Tracker) OnReorg(fn func(number uint64)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.onReorg = append(t.onReorg, fn)
+}
+
+// update records a new head and returns the first replaced block number, 0 when the chain was extended
+func (t *Tracker) update(head Head) uint64 {
+	t.mutex.Lock()
+	var reorgFrom uint64
+	if known, ok := t.hashes[head.Number]; ok && known == head.Hash {
+		t.mutex.Unlock()
+		return 0
+	}
+	if parent, ok := t.hashes[head.Number-1]; ok && head.Number > 0 && parent != head.ParentHash {
+		// the parent was replaced too, the older blocks are checked by the following heads
+		reorgFrom = head.Number - 1
+	} else if head.Number <= t.latest.Number {
+		reorgFrom = head.Number
+	}
+	if reorgFrom > 0 {
+		for number := range t.hashes {
+			if number >= reorgFrom {
+				delete(t.hashes, number)
+			}
+		}
+		t.reorgs++
+	}
+	if head.Number > 0 {
+		t.hashes[head.Number-1] = head.ParentHash
+	}
+	t.hashes[head.Number] = head.Hash
+	for number := range t.hashes {
+		if number+uint64(t.cfg.History) <= head.Number {
+			delete(t.hashes, number)
+		}
+	}
+	t.latest = head
+	if !t.finalityTags && t.cfg.FinalityDepth > 0 && head.Number > t.cfg.FinalityDepth {
+		t.safe = max(t.safe, head.Number-t.cfg.FinalityDepth)
+		t.finalized = max(t.finalized, head.Number-t.cfg.FinalityDepth)
+	}
+	callbacks := t.onReorg
+	t.mutex.Unlock()
+
+	if reorgFrom > 0 {
+		t.logger.Warn("chain reorg", zap.Uint64("from", reorgFrom), zap.Uint64("head", head.Number))
+		for _, fn := range callbacks {
+			fn(reorgFrom)
+		}
+	}
+	return reorgFrom
+}
+
+func (t *Tracker) currentEndpoint() Endpoint {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.cfg.Endpoints[t.endpoint%len(t.cfg.Endpoints)]
+}
+
+// nextEndpoint switches to the next endpoint after a failure
+func (t *Tracker) nextEndpoint(err error) {
+	t.mutex.Lock()
+	t.endpoint++
+	t.mutex.Unlock()
+	t.logger.Warn("head tracking failed, switching endpoint", zap.Error(err))
+}
+
+// followHeads subscribes to newHeads and polls the latest block while no subscription is possible
+func (t *Tracker) followHeads() {
+	for {
+		endpoint := t.currentEndpoint()
+		if endpoint.Ws != "" {
+			err := t.subscribe(endpoint)
+			t.nextEndpoint(err)
+			endpoint = t.currentEndpoint()
+		}
+		if err := t.poll(endpoint); err != nil {
+			t.nextEndpoint(err)
+		}
+		time.Sleep(t.cfg.PollInterval)
+	}
+}
+
+// subscribe returns when the subscription fails
+func (t *Tracker) subscribe(endpoint Endpoint) error {
+	conn, err := utils.DialWs(t.cfg.WsDialer, endpoint.Ws, upstreamauth.Header(endpoint.Auth))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err = conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_subscribe",
+		"params":  []string{"newHeads"},
+	}); err != nil {
+		return err
+	}
+	for {
+		if err = conn.SetReadDeadline(time.Now().Add(t.cfg.PollInterval * 5)); err != nil {
+			return err
+		}
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		notification := jsonrpc.SubscriptionNotification{}
+		if err = json.Unmarshal(message, &notification); err != nil {
+			return err
+		}
+		if notification.Method != "eth_subscription" {
+			// the subscription id or an error
+			resp := jsonrpc.JsonRpcResponse{}
+			if err = json.Unmarshal(message, &resp); err == nil && len(resp.Error) > 0 {
+				return fmt.Errorf("eth_subscribe: %s", resp.Error)
+			}
+			continue
+		}
+		head, err := parseHead(notification.Params.Result)
+		if err != nil {
+			return err
+		}
+		t.update(head)
+	}
+}
+
+func (t *Tracker) poll(endpoint Endpoint) error {
+	result, err := t.call(endpoint, "eth_getBlockByNumber", jsonrpc.EvmBlockLatest, false)
+	if err != nil {
+		return err
+	}
+	head, err := parseHead(result)
+	if err != nil {
+		return err
+	}
+	t.update(head)
+	return nil
+}
+
+// pollFinality reads the safe and finalized blocks, chains without the tags keep the FinalityDepth estimate
+func (t *Tracker) pollFinality() {
+	for ; ; time.Sleep(t.cfg.PollInterval) {
+		endpoint := t.currentEndpoint()
+		for _, tag := range []string{jsonrpc.EvmBlockSafe, jsonrpc.EvmBlockFinalized} {
+			result, err := t.call(endpoint, "eth_getBlockByNumber", tag, false)
+			if err != nil {
+				continue
+			}
+			head, err := parseHead(result)
+			if err != nil {
+				continue
+			}
+			t.mutex.Lock()
+			t.finalityTags = true
+			if tag == jsonrpc.EvmBlockSafe {
+				t.safe = head.Number
+			} else {
+				t.finalized = head.Number
+			}
+			t.mutex.Unlock()
+		}
+	}
+}
+
+func (t *Tracker) reportMetrics() {
+	for ; ; time.Sleep(time.Minute) {
+		t.mutex.RLock()
+		metrics := []prometheus.HeadMetric{
+			{Kind: jsonrpc.EvmBlockLatest, Number: t.latest.Number},
+			{Kind: jsonrpc.EvmBlockSafe, Number: t.safe},
+			{Kind: jsonrpc.EvmBlockFinalized, Number: t.finalized},
+		}
+		reorgs := t.reorgs
+		t.mutex.RUnlock()
+		prometheus.PushHeadMetrics(t.cfg.ChainName, metrics, reorgs)
+	}
+}
+
+// call sends a json-rpc call to the http endpoint and returns its result
+func (t *Tracker) call(endpoint Endpoint, method string, params ...interface{}) (json.RawMessage, error) {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.cfg.PollInterval)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Http, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	upstreamauth.Apply(req.Header, endpoint.Auth)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := t.cfg.HttpClient.Do(req)
+	if err != nil {
+		return nil, upstreamauth.RedactError(err)
+	}
+	defer res.Body.Close()
+	respBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := jsonrpc.JsonRpcResponse{}
+	if err = json.Unmarshal(respBody, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Error) > 0 {
+		return nil, fmt.Errorf("%s: %s", method, resp.Error)
+	}
+	return resp.Result, nil
+}
+
+func parseHead(result json.RawMessage) (Head, error) {
+	var header struct {
+		Number     string `json:"number"`
+		Hash       string `json:"hash"`
+		ParentHash string `json:"parentHash"`
+	}
+	if err := json.Unmarshal(result, &header); err != nil {
+		return Head{}, err
+	}
+	number, err := utils.ToUint64(header.Number)
+	if err != nil {
+		return Head{}, fmt.Errorf("invalid block number %q", header.Number)
+	}
+	return Head{Number: number, Hash: header.Hash, ParentHash: header.ParentHash}, nil
+}
@@ -0,0 +1,47 @@
+package headtracker
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestTrackerUpdate(t *testing.T) {
+	tracker := New(Config{ChainName: "test", History: 4, FinalityDepth: 2}, zap.NewNop())
+	var reorgs []uint64
+	tracker.OnReorg(func(number uint64) {
+		reorgs = append(reorgs, number)
+	})
+
+	for _, head := range []Head{
+		{Number: 10, Hash: "a10", ParentHash: "a9"},
+		{Number: 11, Hash: "a11", ParentHash: "a10"},
+		{Number: 11, Hash: "a11", ParentHash: "a10"}, // duplicate notification
+		{Number: 12, Hash: "a12", ParentHash: "a11"},
+	} {
+		if from := tracker.update(head); from != 0 {
+			t.Fatalf("unexpected reorg from %d at %+v", from, head)
+		}
+	}
+	if tracker.Latest() != 12 || tracker.Finalized() != 10 || tracker.Safe() != 10 {
+		t.Errorf("unexpected head %d safe %d finalized %d", tracker.Latest(), tracker.Safe(), tracker.Finalized())
+	}
+
+	// 12 replaced by b12 on the same parent
+	if from := tracker.update(Head{Number: 12, Hash: "b12", ParentHash: "a11"}); from != 12 {
+		t.Errorf("expected reorg from 12, got %d", from)
+	}
+	// b13 on top of a replaced 12
+	if from := tracker.update(Head{Number: 13, Hash: "c13", ParentHash: "c12"}); from != 12 {
+		t.Errorf("expected reorg from 12, got %d", from)
+	}
+	if hash, _ := tracker.Hash(12); hash != "c12" {
+		t.Errorf("expected hash c12 for block 12, got %s", hash)
+	}
+	if _, ok := tracker.Hash(9); ok {
+		t.Error("expected block 9 to be out of the history")
+	}
+	if len(reorgs) != 2 {
+		t.Errorf("expected 2 reorg callbacks, got %v", reorgs)
+	}
+}
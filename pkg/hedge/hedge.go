@@ -0,0 +1,123 @@
+package hedge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	latencySamples = 512
+	minSamples     = 20
+
+	// DefaultDelay is the hedge delay until enough latencies are known
+	DefaultDelay = time.Second
+	MinDelay     = time.Millisecond * 20
+)
+
+// Latency keeps the recent response times of a chain, the hedge delay is their p95
+type Latency struct {
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func NewLatency() *Latency {
+	return &Latency{samples: make([]time.Duration, 0, latencySamples)}
+}
+
+func (l *Latency) Observe(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySamples
+}
+
+// Delay returns the p95 of the recent response times, at least MinDelay
+func (l *Latency) Delay() time.Duration {
+	l.mutex.Lock()
+	if len(l.samples) < minSamples {
+		l.mutex.Unlock()
+		return DefaultDelay
+	}
+	samples := append([]time.Duration(nil), l.samples...)
+	l.mutex.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return max(samples[len(samples)*95/100], MinDelay)
+}
+
+type result[T any] struct {
+	attempt int
+	value   T
+	err     error
+}
+
+// Do returns the first successful result of primary and secondary. Secondary starts when primary has not
+// returned within delay or failed, it is skipped when nil. The attempt still running is cancelled and a result
+// it returns anyway is passed to release, e.g. to close a response body. Both failing returns the last error.
+func Do[T any](ctx context.Context, delay time.Duration, primary, secondary func(ctx context.Context) (T, error), release func(T)) (T, error) {
+	attempts := []func(ctx context.Context) (T, error){primary}
+	if secondary != nil {
+		attempts = append(attempts, secondary)
+	}
+	results := make(chan result[T], len(attempts))
+	cancels := make([]context.CancelFunc, 0, len(attempts))
+	start := func() {
+		attempt := len(cancels)
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func() {
+			value, err := attempts[attempt](attemptCtx)
+			results <- result[T]{attempt: attempt, value: value, err: err}
+		}()
+	}
+
+	start()
+	pending := 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var err error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if len(cancels) < len(attempts) {
+				start()
+				pending++
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// the winner keeps its context, its result may still be read, e.g. a streamed body
+				for attempt, cancel := range cancels {
+					if attempt != r.attempt {
+						cancel()
+					}
+				}
+				if pending > 0 && release != nil {
+					go func(n int) {
+						for ; n > 0; n-- {
+							if late := <-results; late.err == nil {
+								release(late.value)
+							}
+						}
+					}(pending)
+				}
+				return r.value, nil
+			}
+			err = r.err
+			cancels[r.attempt]()
+			if len(cancels) < len(attempts) {
+				start()
+				pending++
+			}
+		}
+	}
+	var zero T
+	return zero, err
+}
@@ -0,0 +1,60 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLatencyDelay(t *testing.T) {
+	l := NewLatency()
+	if d := l.Delay(); d != DefaultDelay {
+		t.Errorf("expected the default delay without samples, got %s", d)
+	}
+	for i := 1; i <= 100; i++ {
+		l.Observe(time.Duration(i) * time.Millisecond)
+	}
+	if d := l.Delay(); d != 96*time.Millisecond {
+		t.Errorf("expected 96ms, got %s", d)
+	}
+	for i := 0; i < latencySamples; i++ {
+		l.Observe(time.Millisecond)
+	}
+	if d := l.Delay(); d != MinDelay {
+		t.Errorf("expected the min delay, got %s", d)
+	}
+}
+
+func attempt(value string, after time.Duration, err error) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		select {
+		case <-time.After(after):
+			return value, err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name               string
+		primary, secondary func(ctx context.Context) (string, error)
+		value              string
+		err                error
+	}{
+		{"fast primary", attempt("primary", 0, nil), attempt("secondary", 0, nil), "primary", nil},
+		{"slow primary", attempt("primary", time.Second, nil), attempt("secondary", 0, nil), "secondary", nil},
+		{"failed primary", attempt("", 0, failed), attempt("secondary", 0, nil), "secondary", nil},
+		{"both failed", attempt("", 0, failed), attempt("", 0, failed), "", failed},
+		{"no secondary", attempt("primary", time.Millisecond*50, nil), nil, "primary", nil},
+	}
+	for _, test := range tests {
+		value, err := Do(context.Background(), time.Millisecond*10, test.primary, test.secondary, nil)
+		if value != test.value || !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %q %v, got %q %v", test.name, test.value, test.err, value, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"starnet/chain-api/config"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultDialTimeout         = time.Second * 5
+	DefaultTlsHandshakeTimeout = time.Second * 5
+	DefaultIdleConnTimeout     = time.Second * 90
+	DefaultMaxIdleConns        = 256
+	DefaultMaxIdleConnsPerHost = 64
+
+	// DefaultWsUpgradeTimeout bounds the upgrade response of a websocket dial unless ResponseHeaderTimeout is set
+	DefaultWsUpgradeTimeout = time.Second * 10
+)
+
+// New returns the client of the upstream calls of a chain. It has no overall timeout, the calls end with the
+// context of their request.
+func New(cfg config.HttpClientConfig) (*http.Client, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// NewTransport returns the transport of New, zero values of cfg use the defaults
+func NewTransport(cfg config.HttpClientConfig) (*http.Transport, error) {
+	cfg = withDefaults(cfg)
+	proxy, err := newProxy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           newNetDialer(cfg).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.TlsHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     !cfg.DisableHttp2,
+	}
+	if cfg.DisableHttp2 {
+		// a non-nil empty map turns off the automatic http/2 upgrade of tls connections
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
+
+// NewWsDialer returns the dialer of the upstream websockets of a chain, it shares the proxy, tls and timeouts of
+// New. Websockets are upgraded over http/1.1, the http/2 options do not apply.
+func NewWsDialer(cfg config.HttpClientConfig) (*websocket.Dialer, error) {
+	cfg = withDefaults(cfg)
+	proxy, err := newProxy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	upgradeTimeout := cfg.ResponseHeaderTimeout
+	if upgradeTimeout == 0 {
+		upgradeTimeout = DefaultWsUpgradeTimeout
+	}
+	return &websocket.Dialer{
+		Proxy:            proxy,
+		NetDialContext:   newNetDialer(cfg).DialContext,
+		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout: cfg.DialTimeout + cfg.TlsHandshakeTimeout + upgradeTimeout,
+	}, nil
+}
+
+func withDefaults(cfg config.HttpClientConfig) config.HttpClientConfig {
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = DefaultDialTimeout
+	}
+	if cfg.TlsHandshakeTimeout == 0 {
+		cfg.TlsHandshakeTimeout = DefaultTlsHandshakeTimeout
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	return cfg
+}
+
+// newProxy returns the configured proxy, HTTP_PROXY and HTTPS_PROXY without one
+func newProxy(cfg config.HttpClientConfig) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyUrl, err := url.Parse(cfg.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http client proxy: %w", err)
+	}
+	return http.ProxyURL(proxyUrl), nil
+}
+
+func newNetDialer(cfg config.HttpClientConfig) *net.Dialer {
+	return &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: time.Second * 30}
+}
+
+// newTlsConfig trusts CaFile in addition to the system roots and presents the client certificate, nil without both
+func newTlsConfig(cfg config.HttpClientConfig) (*tls.Config, error) {
+	if cfg.CaFile == "" && cfg.CertFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CaFile != "" {
+		pem, err := os.ReadFile(cfg.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read http client ca file: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in http client ca file %s", cfg.CaFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load http client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,82 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"starnet/chain-api/config"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport, err := NewTransport(config.HttpClientConfig{MaxIdleConnsPerHost: 8, DisableHttp2: true, Proxy: "socks5://127.0.0.1:1080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.MaxIdleConnsPerHost != 8 || transport.MaxIdleConns != DefaultMaxIdleConns || transport.TLSHandshakeTimeout != DefaultTlsHandshakeTimeout {
+		t.Errorf("unexpected pool settings %d %d %s", transport.MaxIdleConnsPerHost, transport.MaxIdleConns, transport.TLSHandshakeTimeout)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("expected http/2 to be disabled")
+	}
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "https://node.example", nil))
+	if err != nil || proxy.String() != "socks5://127.0.0.1:1080" {
+		t.Errorf("unexpected proxy %v %v", proxy, err)
+	}
+
+	if _, err = NewTransport(config.HttpClientConfig{CaFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected an error for a missing ca file")
+	}
+}
+
+func TestNewWsDialer(t *testing.T) {
+	dialer, err := NewWsDialer(config.HttpClientConfig{ResponseHeaderTimeout: time.Second, Proxy: "http://127.0.0.1:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := DefaultDialTimeout + DefaultTlsHandshakeTimeout + time.Second; dialer.HandshakeTimeout != want {
+		t.Errorf("unexpected handshake timeout %s, want %s", dialer.HandshakeTimeout, want)
+	}
+	if dialer.NetDialContext == nil {
+		t.Error("expected the dial timeout to be applied")
+	}
+	proxy, err := dialer.Proxy(httptest.NewRequest(http.MethodGet, "https://node.example", nil))
+	if err != nil || proxy.String() != "http://127.0.0.1:3128" {
+		t.Errorf("unexpected proxy %v %v", proxy, err)
+	}
+
+	if _, err = NewWsDialer(config.HttpClientConfig{CaFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected an error for a missing ca file")
+	}
+}
+
+func TestCaFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := New(config.HttpClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Get(server.URL); err == nil {
+		t.Fatal("expected the test server certificate to be untrusted")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err = os.WriteFile(caFile, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if client, err = New(config.HttpClientConfig{CaFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
@@ -5,7 +5,6 @@
 package initapp
 
 import (
-	"net/http"
 	"starnet/chain-api/pkg/app"
 	"starnet/chain-api/pkg/handler"
 	"starnet/chain-api/pkg/proxy"
@@ -65,13 +64,28 @@ func initArbitrumHandler(app *app.App) error {
 		"web3_clientVersion",
 	}
 
+	client, wsDialer, err := newUpstreamClient(app.Config.Upstream.Arbitrum.HttpClient, app.Config.Upstream.Arbitrum.Auth, app.Config.Upstream.Arbitrum.Pools)
+	if err != nil {
+		return err
+	}
+
+	headTracker := newHeadTracker(app, chain, client, wsDialer, app.Config.Upstream.Arbitrum.Http, app.Config.Upstream.Arbitrum.Ws, app.Config.Upstream.Arbitrum.Auth, time.Second, 0)
+	httpBlackMethods = withoutFilterMethods(httpBlackMethods, headTracker)
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream:     app.Config.Upstream.Arbitrum.Http,
 		WsUpstream:       app.Config.Upstream.Arbitrum.Ws,
-		HttpClient:       http.DefaultClient,
+		Auth:             app.Config.Upstream.Arbitrum.Auth,
+		Pools:            app.Config.Upstream.Arbitrum.Pools,
+		Routes:           append(app.Config.Upstream.Arbitrum.Routes, evmArchiveRoutes(app.Config.Upstream.Arbitrum.UpstreamRouting)...),
+		HedgeUpstreams:   app.Config.Upstream.Arbitrum.Hedge,
+		HttpClient:       client,
+		WsDialer:         wsDialer,
 		CacheTime:        time.Second * 1, // L1 15 seconds L2 1 minutes  https://developer.offchainlabs.com/docs/time_in_arbitrum
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: cacheableMethods,
+		HeadTracker:      headTracker,
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -85,6 +99,15 @@ func initArbitrumHandler(app *app.App) error {
 		p,
 		app,
 	)
+	h.SetRequestTimeout(app.Config.Upstream.Arbitrum.HttpClient.RequestTimeout)
+	setFilters(app, h, p, chain, headTracker)
+	setSubscriptions(app, h, chain, wsDialer, app.Config.Upstream.Arbitrum.Ws, app.Config.Upstream.Arbitrum.Auth)
+
+	sanitizer, err := newSanitizer(evmSanitizeRules, app.Config.Upstream.Arbitrum.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
 
 	app.ArbitrumHttpHandler = h
 	app.ArbitrumWsHandler = h
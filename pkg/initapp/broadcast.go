@@ -0,0 +1,44 @@
+package initapp
+
+import (
+	"net/http"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/broadcast"
+	"starnet/chain-api/pkg/handler"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/starnet/constant"
+)
+
+// tendermintBroadcastMethods broadcast_tx_commit waits for the block and stays on the upstream
+var tendermintBroadcastMethods = []string{
+	"broadcast_tx_sync",
+	"broadcast_tx_async",
+}
+
+// setBroadcaster sends the broadcast calls of a chain to its upstream and the public relays at once
+func setBroadcaster(
+	app *app.App,
+	h *handler.JsonRpcHandler,
+	chain constant.Chain,
+	client *http.Client,
+	upstream string,
+	auth config.UpstreamAuth,
+	relays []string,
+	methods []string,
+	txHash func(call *jsonrpc.JsonRpcSingleRequest) (string, error),
+) {
+	targets := []broadcast.Target{{Name: "upstream", Url: upstream, Auth: auth}}
+	for _, relay := range relays {
+		targets = append(targets, broadcast.Target{Name: relay, Url: relay})
+	}
+
+	b := broadcast.New(app.Rdb, app.Logger, broadcast.Config{
+		ChainName:  chain.Name,
+		HttpClient: client,
+		Background: app.Background,
+		TxHash:     txHash,
+	})
+	h.SetBroadcaster(b, methods, targets)
+}
@@ -1,10 +1,10 @@
 package initapp
 
 import (
-	"net/http"
 	"time"
 
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/broadcast"
 	"starnet/chain-api/pkg/handler"
 	"starnet/chain-api/pkg/proxy"
 	"starnet/starnet/constant"
@@ -14,13 +14,23 @@ func initCosmosHandler(app *app.App) error {
 	chain := constant.ChainCosmos
 	chainUpstreamCfg := app.Config.Upstream.Cosmos
 
+	client, wsDialer, err := newUpstreamClient(chainUpstreamCfg.HttpClient, chainUpstreamCfg.Auth, nil)
+	if err != nil {
+		return err
+	}
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream:     chainUpstreamCfg.Http,
 		WsUpstream:       chainUpstreamCfg.Ws,
-		HttpClient:       http.DefaultClient,
+		Auth:             chainUpstreamCfg.Auth,
+		HttpClient:       client,
+		WsDialer:         wsDialer,
 		CacheTime:        time.Second * 6, // block time 3s
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: tendermintCacheableMethods,
+
+		TendermintCacheTimeFn: tendermintCacheTime(time.Second * 6),
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -29,16 +39,29 @@ func initCosmosHandler(app *app.App) error {
 
 	h := handler.NewJsonRpcHandler(
 		chain,
-		[]string{},
 		tendermintHttpBlackMethods,
+		[]string{},
 		tendermintWsBlackMethods,
 		justWhiteMethods,
 		p,
 		app,
 	)
+	h.SetRequestTimeout(chainUpstreamCfg.HttpClient.RequestTimeout)
+
+	sanitizer, err := newSanitizer(tendermintSanitizeRules, chainUpstreamCfg.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
+	setBroadcaster(app, h, chain, client, chainUpstreamCfg.Http, chainUpstreamCfg.Auth, chainUpstreamCfg.Relays, tendermintBroadcastMethods, broadcast.TendermintTxHash)
 
 	app.CosmosHttpHandler = h
 	app.CosmosWsHandler = h
+	app.CosmosLcdHandler = newLcdHandler(app, chain, client, chainUpstreamCfg.Lcd, chainUpstreamCfg.Auth)
+
+	if err := initGrpcHandler(app, "cosmos", chain, chainUpstreamCfg); err != nil {
+		return err
+	}
 
 	return nil
 }
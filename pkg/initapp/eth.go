@@ -5,8 +5,9 @@
 package initapp
 
 import (
-	"net/http"
+	"starnet/chain-api/config"
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/broadcast"
 	"starnet/chain-api/pkg/handler"
 	"starnet/chain-api/pkg/proxy"
 	"starnet/starnet/constant"
@@ -75,17 +76,40 @@ func initEthHandler(app *app.App) error {
 		"debug_traceBlockByHash",
 	}
 
+	erigon := app.Config.Upstream.Eth.Erigon
+	pools, routes := upstreamRouting(
+		app.Config.Upstream.Eth.UpstreamRouting,
+		map[string]config.UpstreamPool{erigonPool: {Http: erigon.Http, Ws: erigon.Ws, Auth: erigon.Auth}},
+		append(evmArchiveRoutes(app.Config.Upstream.Eth.UpstreamRouting), config.UpstreamRoute{Methods: erigonMethods, Pool: erigonPool})...,
+	)
+
+	client, wsDialer, err := newUpstreamClient(app.Config.Upstream.Eth.HttpClient, app.Config.Upstream.Eth.Auth, pools)
+	if err != nil {
+		return err
+	}
+
+	headTracker := newHeadTracker(app, chain, client, wsDialer, app.Config.Upstream.Eth.Http, app.Config.Upstream.Eth.Ws, app.Config.Upstream.Eth.Auth, time.Second*12, 64)
+	httpBlackMethods = withoutFilterMethods(httpBlackMethods, headTracker)
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream: app.Config.Upstream.Eth.Http,
 		WsUpstream:   app.Config.Upstream.Eth.Ws,
+		Auth:         app.Config.Upstream.Eth.Auth,
 
-		HttpErigonStream: app.Config.Upstream.Eth.Erigon.Http,
-		WsErigonUpstream: app.Config.Upstream.Eth.Erigon.Ws,
+		Pools:  pools,
+		Routes: routes,
 
-		HttpClient:       http.DefaultClient,
+		HedgeUpstreams: app.Config.Upstream.Eth.Hedge,
+
+		HttpPrivateUpstream: app.Config.Upstream.Eth.Private.Http,
+
+		HttpClient:       client,
+		WsDialer:         wsDialer,
 		CacheTime:        time.Second * 12,
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: cacheableMethods,
+		HeadTracker:      headTracker,
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -99,6 +123,26 @@ func initEthHandler(app *app.App) error {
 		p,
 		app,
 	)
+	h.SetRequestTimeout(app.Config.Upstream.Eth.HttpClient.RequestTimeout)
+	setFilters(app, h, p, chain, headTracker)
+	setSubscriptions(app, h, chain, wsDialer, app.Config.Upstream.Eth.Ws, app.Config.Upstream.Eth.Auth)
+
+	sanitizer, err := newSanitizer(evmSanitizeRules, app.Config.Upstream.Eth.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
+	setBroadcaster(app, h, chain, client, cfg.HttpUpstream, app.Config.Upstream.Eth.Auth, app.Config.Upstream.Eth.Relays, []string{"eth_sendRawTransaction"}, broadcast.EvmTxHash)
+
+	if private := app.Config.Upstream.Eth.Private; private.Http != "" {
+		h.SetPrivateTx(&handler.PrivateTxConfig{
+			Methods:        []string{"eth_sendRawTransaction"},
+			ApiKeys:        private.ApiKeys,
+			FallbackBlocks: private.FallbackBlocks,
+			BlockTime:      time.Second * 12,
+			TxHash:         broadcast.EvmTxHash,
+		})
+	}
 
 	app.EthHttpHandler = h
 	app.EthWsHandler = h
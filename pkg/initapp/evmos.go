@@ -1,10 +1,10 @@
 package initapp
 
 import (
-	"net/http"
 	"time"
 
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/broadcast"
 	"starnet/chain-api/pkg/handler"
 	"starnet/chain-api/pkg/proxy"
 	"starnet/starnet/constant"
@@ -14,13 +14,23 @@ func initEvmosHandler(app *app.App) error {
 	chain := constant.ChainEvmos
 	chainUpstreamCfg := app.Config.Upstream.Evmos
 
+	client, wsDialer, err := newUpstreamClient(chainUpstreamCfg.HttpClient, chainUpstreamCfg.Auth, nil)
+	if err != nil {
+		return err
+	}
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream:     chainUpstreamCfg.Http,
 		WsUpstream:       chainUpstreamCfg.Ws,
-		HttpClient:       http.DefaultClient,
-		CacheTime:        time.Second * 4,  // block time 4.26s https://escan.live/
+		Auth:             chainUpstreamCfg.Auth,
+		HttpClient:       client,
+		WsDialer:         wsDialer,
+		CacheTime:        time.Second * 4, // block time 4.26s https://escan.live/
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: tendermintCacheableMethods,
+
+		TendermintCacheTimeFn: tendermintCacheTime(time.Second * 4),
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -36,9 +46,22 @@ func initEvmosHandler(app *app.App) error {
 		p,
 		app,
 	)
+	h.SetRequestTimeout(chainUpstreamCfg.HttpClient.RequestTimeout)
+
+	sanitizer, err := newSanitizer(tendermintSanitizeRules, chainUpstreamCfg.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
+	setBroadcaster(app, h, chain, client, chainUpstreamCfg.Http, chainUpstreamCfg.Auth, chainUpstreamCfg.Relays, tendermintBroadcastMethods, broadcast.TendermintTxHash)
 
 	app.EvmosHttpHandler = h
 	app.EvmosWsHandler = h
+	app.EvmosLcdHandler = newLcdHandler(app, chain, client, chainUpstreamCfg.Lcd, chainUpstreamCfg.Auth, "evmos", "ethermint")
+
+	if err := initGrpcHandler(app, "evmos", chain, chainUpstreamCfg, "evmos", "ethermint"); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,38 @@
+package initapp
+
+import (
+	"context"
+	"encoding/json"
+
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/filter"
+	"starnet/chain-api/pkg/handler"
+	"starnet/chain-api/pkg/proxy"
+	"starnet/starnet/constant"
+
+	"github.com/samber/lo"
+)
+
+// withoutFilterMethods allows the filter methods over http when the chain head is tracked to emulate them
+func withoutFilterMethods(httpBlackMethods []string, headTracker app.HeadTracker) []string {
+	if headTracker == nil {
+		return httpBlackMethods
+	}
+	return lo.Without(httpBlackMethods, filter.Methods...)
+}
+
+// setFilters answers the filter methods with filters kept in redis instead of the node
+func setFilters(app *app.App, h *handler.JsonRpcHandler, p *proxy.JsonRpcProxy, chain constant.Chain, headTracker app.HeadTracker) {
+	if headTracker == nil {
+		return
+	}
+	h.SetFilters(filter.New(app.Rdb, headTracker, filter.Config{
+		ChainName: chain.Name,
+		Call: func(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+			return p.Call(ctx, app.Logger, method, params...)
+		},
+		LogsHead: func(ctx context.Context, criteria map[string]json.RawMessage) (uint64, error) {
+			return p.BlockNumberOf(ctx, app.Logger, "eth_getLogs", criteria)
+		},
+	}))
+}
@@ -1,10 +1,10 @@
 package initapp
 
 import (
-	"net/http"
 	"time"
 
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/broadcast"
 	"starnet/chain-api/pkg/handler"
 	"starnet/chain-api/pkg/proxy"
 	"starnet/starnet/constant"
@@ -14,13 +14,23 @@ func initGravityHandler(app *app.App) error {
 	chain := constant.ChainGravity
 	chainUpstreamCfg := app.Config.Upstream.Gravity
 
+	client, wsDialer, err := newUpstreamClient(chainUpstreamCfg.HttpClient, chainUpstreamCfg.Auth, nil)
+	if err != nil {
+		return err
+	}
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream:     chainUpstreamCfg.Http,
 		WsUpstream:       chainUpstreamCfg.Ws,
-		HttpClient:       http.DefaultClient,
+		Auth:             chainUpstreamCfg.Auth,
+		HttpClient:       client,
+		WsDialer:         wsDialer,
 		CacheTime:        time.Second * 6,
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: tendermintCacheableMethods,
+
+		TendermintCacheTimeFn: tendermintCacheTime(time.Second * 6),
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -36,9 +46,22 @@ func initGravityHandler(app *app.App) error {
 		p,
 		app,
 	)
+	h.SetRequestTimeout(chainUpstreamCfg.HttpClient.RequestTimeout)
+
+	sanitizer, err := newSanitizer(tendermintSanitizeRules, chainUpstreamCfg.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
+	setBroadcaster(app, h, chain, client, chainUpstreamCfg.Http, chainUpstreamCfg.Auth, chainUpstreamCfg.Relays, tendermintBroadcastMethods, broadcast.TendermintTxHash)
 
 	app.GravityHttpHandler = h
 	app.GravityWsHandler = h
+	app.GravityLcdHandler = newLcdHandler(app, chain, client, chainUpstreamCfg.Lcd, chainUpstreamCfg.Auth, "gravity")
+
+	if err := initGrpcHandler(app, "gravity", chain, chainUpstreamCfg, "gravity"); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,32 @@
+package initapp
+
+import (
+	"net/http"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/headtracker"
+	"starnet/starnet/constant"
+
+	"github.com/gorilla/websocket"
+)
+
+// newHeadTracker starts following the head of an evm chain upstream, it returns nil without upstream
+func newHeadTracker(app *app.App, chain constant.Chain, client *http.Client, wsDialer *websocket.Dialer, httpUpstream, wsUpstream string, auth config.UpstreamAuth, blockTime time.Duration, finalityDepth uint64) app.HeadTracker {
+	if httpUpstream == "" {
+		return nil
+	}
+	tracker := headtracker.New(headtracker.Config{
+		ChainName:     chain.Name,
+		Endpoints:     []headtracker.Endpoint{{Http: httpUpstream, Ws: wsUpstream, Auth: auth}},
+		HttpClient:    client,
+		WsDialer:      wsDialer,
+		PollInterval:  blockTime,
+		FinalityDepth: finalityDepth,
+		PushMetrics:   app.RpcConfig != nil && app.RpcConfig.HealthPushgateway != "",
+	}, app.Logger)
+	tracker.Start()
+	app.HeadTrackers[chain.Name] = tracker
+	return tracker
+}
@@ -5,7 +5,6 @@
 package initapp
 
 import (
-	"net/http"
 	"starnet/chain-api/pkg/app"
 	"starnet/chain-api/pkg/handler"
 	"starnet/chain-api/pkg/proxy"
@@ -70,13 +69,28 @@ func initHscHandler(app *app.App) error {
 		"web3_clientVersion",
 	}
 
+	client, wsDialer, err := newUpstreamClient(chainUpstreamCfg.HttpClient, chainUpstreamCfg.Auth, chainUpstreamCfg.Pools)
+	if err != nil {
+		return err
+	}
+
+	headTracker := newHeadTracker(app, chain, client, wsDialer, chainUpstreamCfg.Http, chainUpstreamCfg.Ws, chainUpstreamCfg.Auth, time.Second*3, 15)
+	httpBlackMethods = withoutFilterMethods(httpBlackMethods, headTracker)
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream:     chainUpstreamCfg.Http,
 		WsUpstream:       chainUpstreamCfg.Ws,
-		HttpClient:       http.DefaultClient,
+		Auth:             chainUpstreamCfg.Auth,
+		Pools:            chainUpstreamCfg.Pools,
+		Routes:           append(chainUpstreamCfg.Routes, evmArchiveRoutes(chainUpstreamCfg.UpstreamRouting)...),
+		HedgeUpstreams:   chainUpstreamCfg.Hedge,
+		HttpClient:       client,
+		WsDialer:         wsDialer,
 		CacheTime:        time.Second * 6, // block time 3s
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: cacheableMethods,
+		HeadTracker:      headTracker,
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -90,6 +104,15 @@ func initHscHandler(app *app.App) error {
 		p,
 		app,
 	)
+	h.SetRequestTimeout(chainUpstreamCfg.HttpClient.RequestTimeout)
+	setFilters(app, h, p, chain, headTracker)
+	setSubscriptions(app, h, chain, wsDialer, chainUpstreamCfg.Ws, chainUpstreamCfg.Auth)
+
+	sanitizer, err := newSanitizer(evmSanitizeRules, app.Config.Upstream.Hsc.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
 
 	app.HscHttpHandler = h
 	app.HscWsHandler = h
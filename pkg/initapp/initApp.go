@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 
+	"starnet/chain-api/pkg/chaintype"
 	"starnet/chain-api/pkg/db"
 	"starnet/chain-api/pkg/prometheus"
 	"starnet/chain-api/service"
@@ -66,6 +67,9 @@ func NewApp(configFile string, rpcConfigFile string) *app.App {
 		if err != nil {
 			logger.Fatal("fail to load rpc config", zap.Error(err))
 		}
+		if err = chaintype.Configure(rpcConfig); err != nil {
+			logger.Fatal("fail to configure rpc chains", zap.Error(err))
+		}
 		prometheus.PushgatewayBase = rpcConfig.HealthPushgateway
 	}
 
@@ -77,6 +81,11 @@ func NewApp(configFile string, rpcConfigFile string) *app.App {
 		DB:          _db,
 		RateLimiter: rateLimiter,
 		IPFSSrv:     ipfsSrv,
+
+		Websockets:   app.NewWebsockets(),
+		Background:   app.NewBackground(),
+		GrpcHandlers: map[string]app.GrpcHandler{},
+		HeadTrackers: map[string]app.HeadTracker{},
 	}
 
 	initFns := []func(app *app.App) error{
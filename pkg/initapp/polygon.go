@@ -5,7 +5,6 @@
 package initapp
 
 import (
-	"net/http"
 	"time"
 
 	"starnet/chain-api/pkg/app"
@@ -81,13 +80,28 @@ func initPolygonHandler(app *app.App) error {
 		"web3_clientVersion",
 	}
 
+	client, wsDialer, err := newUpstreamClient(app.Config.Upstream.Polygon.HttpClient, app.Config.Upstream.Polygon.Auth, app.Config.Upstream.Polygon.Pools)
+	if err != nil {
+		return err
+	}
+
+	headTracker := newHeadTracker(app, chain, client, wsDialer, app.Config.Upstream.Polygon.Http, app.Config.Upstream.Polygon.Ws, app.Config.Upstream.Polygon.Auth, time.Second*2, 256)
+	httpBlackMethods = withoutFilterMethods(httpBlackMethods, headTracker)
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream:     app.Config.Upstream.Polygon.Http,
 		WsUpstream:       app.Config.Upstream.Polygon.Ws,
-		HttpClient:       http.DefaultClient,
+		Auth:             app.Config.Upstream.Polygon.Auth,
+		Pools:            app.Config.Upstream.Polygon.Pools,
+		Routes:           append(app.Config.Upstream.Polygon.Routes, evmArchiveRoutes(app.Config.Upstream.Polygon.UpstreamRouting)...),
+		HedgeUpstreams:   app.Config.Upstream.Polygon.Hedge,
+		HttpClient:       client,
+		WsDialer:         wsDialer,
 		CacheTime:        time.Second * 2, // block time 2.3s https://www.blocknative.com/blog/monitor-polygon-mempool
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: cacheableMethods,
+		HeadTracker:      headTracker,
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -101,6 +115,15 @@ func initPolygonHandler(app *app.App) error {
 		p,
 		app,
 	)
+	h.SetRequestTimeout(app.Config.Upstream.Polygon.HttpClient.RequestTimeout)
+	setFilters(app, h, p, chain, headTracker)
+	setSubscriptions(app, h, chain, wsDialer, app.Config.Upstream.Polygon.Ws, app.Config.Upstream.Polygon.Auth)
+
+	sanitizer, err := newSanitizer(evmSanitizeRules, app.Config.Upstream.Polygon.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
 
 	app.PolygonHttpHandler = h
 	app.PolygonWsHandler = h
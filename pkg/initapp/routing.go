@@ -0,0 +1,49 @@
+package initapp
+
+import (
+	"starnet/chain-api/config"
+)
+
+const (
+	// erigonPool serves the erigonMethods of eth, the upstream.eth.erigon config
+	erigonPool = "erigon"
+
+	// archivePool gets the state reads of evm chains at blocks older than evmArchiveDepth when it is configured
+	archivePool = "archive"
+
+	// evmArchiveDepth geth full nodes keep the state of the last 128 blocks
+	evmArchiveDepth = 128
+)
+
+var evmArchiveMethods = []string{
+	"eth_getBalance",
+	"eth_getCode",
+	"eth_getTransactionCount",
+	"eth_getStorageAt",
+	"eth_call",
+	"eth_estimateGas",
+	"eth_createAccessList",
+	"eth_getProof",
+	"debug_traceCall",
+}
+
+// evmArchiveRoutes returns the default archive route of an evm chain with an archive pool
+func evmArchiveRoutes(routing config.UpstreamRouting) []config.UpstreamRoute {
+	if _, ok := routing.Pools[archivePool]; !ok {
+		return nil
+	}
+	return []config.UpstreamRoute{{Methods: evmArchiveMethods, Pool: archivePool, MinBlockDepth: evmArchiveDepth}}
+}
+
+// upstreamRouting returns the configured pools with the extra ones, configured routes are matched before the
+// default routes so they can move a method to another pool
+func upstreamRouting(routing config.UpstreamRouting, pools map[string]config.UpstreamPool, routes ...config.UpstreamRoute) (map[string]config.UpstreamPool, []config.UpstreamRoute) {
+	merged := make(map[string]config.UpstreamPool, len(routing.Pools)+len(pools))
+	for name, pool := range pools {
+		merged[name] = pool
+	}
+	for name, pool := range routing.Pools {
+		merged[name] = pool
+	}
+	return merged, append(append([]config.UpstreamRoute{}, routing.Routes...), routes...)
+}
@@ -0,0 +1,55 @@
+package initapp
+
+import (
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/sanitize"
+)
+
+// newSanitizer applies the configured rules of a chain after its built in rules, which can not be turned off
+func newSanitizer(builtin, configured []config.SanitizeRule) (*sanitize.Pipeline, error) {
+	return sanitize.New(append(append([]config.SanitizeRule{}, builtin...), configured...))
+}
+
+var (
+	// tendermintSanitizeRules hide the rpc and peer addresses of our nodes
+	tendermintSanitizeRules = []config.SanitizeRule{
+		{
+			Methods: []string{"status"},
+			Redact:  []string{"/result/node_info/other/rpc_address"},
+		},
+		{
+			Methods: []string{"net_info"},
+			Redact: []string{
+				"/result/listeners",
+				"/result/peers/*/remote_ip",
+				"/result/peers/*/node_info/listen_addr",
+				"/result/peers/*/node_info/other/rpc_address",
+			},
+		},
+	}
+
+	// evmSanitizeRules hide peer addresses and the client build, only the client name is kept
+	evmSanitizeRules = []config.SanitizeRule{
+		{
+			Methods: []string{"admin_peers"},
+			Redact: []string{
+				"/result/*/enode",
+				"/result/*/network/localAddress",
+				"/result/*/network/remoteAddress",
+			},
+		},
+		{
+			Methods: []string{"admin_nodeInfo"},
+			Redact: []string{
+				"/result/enode",
+				"/result/enr",
+				"/result/ip",
+				"/result/listenAddr",
+			},
+		},
+		{
+			Methods: []string{"web3_clientVersion"},
+			Jq:      `if (.result | type) == "string" then .result |= (split("/") | .[0]) else . end`,
+		},
+	}
+)
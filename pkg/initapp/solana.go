@@ -5,21 +5,97 @@
 package initapp
 
 import (
-	"net/http"
 	"time"
 
 	"starnet/chain-api/pkg/app"
 	"starnet/chain-api/pkg/handler"
+	"starnet/chain-api/pkg/jsonrpc"
 	"starnet/chain-api/pkg/proxy"
+	"starnet/chain-api/pkg/utils"
 	"starnet/starnet/constant"
 )
 
+var (
+	// solanaSubscriptionMethods only work over websocket
+	solanaSubscriptionMethods = []string{
+		"accountSubscribe",
+		"accountUnsubscribe",
+		"logsSubscribe",
+		"logsUnsubscribe",
+		"programSubscribe",
+		"programUnsubscribe",
+		"rootSubscribe",
+		"rootUnsubscribe",
+		"signatureSubscribe",
+		"signatureUnsubscribe",
+		"slotSubscribe",
+		"slotUnsubscribe",
+		"slotsUpdatesSubscribe",
+		"slotsUpdatesUnsubscribe",
+	}
+
+	// solanaImmutableMethods return the same result for the same params once the data is finalized
+	solanaImmutableMethods = []string{
+		"getBlock",
+		"getBlockTime",
+		"getTransaction",
+		"getConfirmedBlock",
+		"getConfirmedTransaction",
+	}
+
+	solanaMethodCosts = map[string]int{
+		"getProgramAccounts":                10,
+		"getLargestAccounts":                10,
+		"getSupply":                         5,
+		"getBlock":                          5,
+		"getConfirmedBlock":                 5,
+		"getBlocks":                         2,
+		"getBlocksWithLimit":                2,
+		"getConfirmedBlocks":                2,
+		"getConfirmedBlocksWithLimit":       2,
+		"getTransaction":                    2,
+		"getConfirmedTransaction":           2,
+		"getMultipleAccounts":               2,
+		"getSignaturesForAddress":           3,
+		"getConfirmedSignaturesForAddress2": 3,
+		"getTokenAccountsByOwner":           3,
+		"getTokenAccountsByDelegate":        3,
+		"getTokenLargestAccounts":           3,
+		"getLeaderSchedule":                 3,
+		"getVoteAccounts":                   3,
+	}
+)
+
+// solanaCacheTime keeps finalized blocks and transactions for a long time, short-lived state at confirmed
+// commitment only for a slot or two and never caches processed state which may be rolled back.
+func solanaCacheTime(req *jsonrpc.JsonRpcSingleRequest) time.Duration {
+	switch jsonrpc.SolanaCommitment(req.Params) {
+	case jsonrpc.SolanaCommitmentProcessed:
+		return 0
+	case jsonrpc.SolanaCommitmentFinalized:
+		if utils.In(req.Method, solanaImmutableMethods) {
+			return time.Hour
+		}
+	}
+	return time.Second * 1
+}
+
 func initSolanaHandler(app *app.App) error {
 	chain := constant.ChainSolana
 
-	var httpBlackMethods []string
-	var wsBlackMethods []string
-	var justWhiteMethods []string
+	httpBlackMethods := solanaSubscriptionMethods
+
+	// unstable subscriptions which stream every block or vote
+	wsBlackMethods := []string{
+		"blockSubscribe",
+		"blockUnsubscribe",
+		"voteSubscribe",
+		"voteUnsubscribe",
+	}
+
+	justWhiteMethods := []string{
+		"getLargestAccounts",
+	}
 
 	cacheableMethods := []string{
 		"getAccountInfo",
@@ -84,13 +160,25 @@ func initSolanaHandler(app *app.App) error {
 		"getSnapshotSlot",
 	}
 
+	client, wsDialer, err := newUpstreamClient(app.Config.Upstream.Solana.HttpClient, app.Config.Upstream.Solana.Auth, app.Config.Upstream.Solana.Pools)
+	if err != nil {
+		return err
+	}
+
 	cfg := proxy.JsonRpcProxyConfig{
 		HttpUpstream:     app.Config.Upstream.Solana.Http,
 		WsUpstream:       app.Config.Upstream.Solana.Ws,
-		HttpClient:       http.DefaultClient,
+		Auth:             app.Config.Upstream.Solana.Auth,
+		Pools:            app.Config.Upstream.Solana.Pools,
+		Routes:           app.Config.Upstream.Solana.Routes,
+		HedgeUpstreams:   app.Config.Upstream.Solana.Hedge,
+		HttpClient:       client,
+		WsDialer:         wsDialer,
 		CacheTime:        time.Second * 1, // block time 400ms https://www.finextra.com/blogposting/21693/introduction-to-the-solana-blockchain
 		ChainID:          chain.ChainID,
+		ChainName:        chain.Name,
 		CacheableMethods: cacheableMethods,
+		CacheTimeFn:      solanaCacheTime,
 	}
 
 	p := proxy.NewJsonRpcProxy(app, cfg)
@@ -104,6 +192,15 @@ func initSolanaHandler(app *app.App) error {
 		p,
 		app,
 	)
+	h.SetRequestTimeout(app.Config.Upstream.Solana.HttpClient.RequestTimeout)
+	h.SetMethodCosts(solanaMethodCosts)
+	h.SetValidator(jsonrpc.ValidateSolanaRequest)
+
+	sanitizer, err := newSanitizer(nil, app.Config.Upstream.Solana.Sanitize)
+	if err != nil {
+		return err
+	}
+	h.SetSanitizer(sanitizer)
 
 	app.SolanaHttpHandler = h
 	app.SolanaWsHandler = h
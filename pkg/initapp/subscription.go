@@ -0,0 +1,19 @@
+package initapp
+
+import (
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/handler"
+	"starnet/chain-api/pkg/subscription"
+	"starnet/starnet/constant"
+
+	"github.com/gorilla/websocket"
+)
+
+// setSubscriptions serves the common evm subscriptions from one upstream connection per chain
+func setSubscriptions(app *app.App, h *handler.JsonRpcHandler, chain constant.Chain, wsDialer *websocket.Dialer, ws string, auth config.UpstreamAuth) {
+	if ws == "" {
+		return
+	}
+	h.SetSubscriptions(subscription.New(subscription.Config{ChainName: chain.Name, Ws: ws, Auth: auth, WsDialer: wsDialer}, app.Logger))
+}
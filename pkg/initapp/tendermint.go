@@ -1,5 +1,18 @@
 package initapp
 
+import (
+	"net/http"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/handler"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/proxy"
+	"starnet/chain-api/pkg/utils"
+	"starnet/starnet/constant"
+)
+
 var (
 	tendermintHttpBlackMethods = []string{
 		"dial_seeds",
@@ -40,3 +53,133 @@ var (
 		"abci_query",      // fixme: error converting http params or panic message
 	}
 )
+
+var (
+	// tendermintHeightMethods return data of a fixed block when called with a height
+	tendermintHeightMethods = []string{
+		"block",
+		"block_results",
+		"commit",
+		"validators",
+		"consensus_params",
+		"abci_query",
+	}
+
+	// tendermintFinalMethods return data which never changes once found, tendermint has instant finality
+	tendermintFinalMethods = []string{
+		"block_by_hash",
+		"tx",
+		"genesis",
+		"genesis_chunked",
+	}
+
+	// tendermintLiveMethods report the node state
+	tendermintLiveMethods = []string{
+		"status",
+		"health",
+		"net_info",
+		"abci_info",
+		"unconfirmed_txs",
+		"num_unconfirmed_txs",
+		"consensus_state",
+		"dump_consensus_state",
+	}
+)
+
+// tendermintCacheTime caches data at a fixed height for a day, the node state for a second and other calls for blockTime
+func tendermintCacheTime(blockTime time.Duration) func(req *jsonrpc.TenderMintRequest) time.Duration {
+	return func(req *jsonrpc.TenderMintRequest) time.Duration {
+		switch {
+		case req.Height() > 0 && utils.In(req.Path, tendermintHeightMethods):
+			return time.Hour * 24
+		case utils.In(req.Path, tendermintFinalMethods):
+			return time.Hour * 24
+		case utils.In(req.Path, tendermintLiveMethods):
+			return time.Second
+		default:
+			return blockTime
+		}
+	}
+}
+
+var (
+	// cosmosModules sdk modules served by every lcd and grpc route, chains append their own modules
+	cosmosModules = []string{
+		"cosmos",
+		"ibc",
+	}
+
+	lcdDenyPaths = []string{
+		"/cosmos/base/tendermint/v1beta1/node_info", // exposes the node listen addresses
+	}
+
+	grpcDenyMethods = []string{
+		"/cosmos.base.tendermint.v1beta1.Service/GetNodeInfo", // exposes the node listen addresses
+	}
+
+	// lcdWritePaths the only lcd paths accepting POST
+	lcdWritePaths = []string{
+		"/cosmos/tx/v1beta1/txs",
+		"/cosmos/tx/v1beta1/simulate",
+	}
+)
+
+// newLcdHandler returns nil when the chain has no lcd upstream configured
+func newLcdHandler(app *app.App, chain constant.Chain, client *http.Client, upstream string, auth config.UpstreamAuth, modules ...string) app.LcdHandler {
+	if upstream == "" {
+		return nil
+	}
+
+	var allowPaths []string
+	for _, module := range append(modules, cosmosModules...) {
+		allowPaths = append(allowPaths, "/"+module+"/")
+	}
+
+	p := proxy.NewLcdProxy(app, proxy.LcdProxyConfig{
+		Upstream:   upstream,
+		Auth:       auth,
+		HttpClient: client,
+		ChainID:    chain.ChainID,
+		CacheTime:  time.Hour, // height pinned queries never change
+	})
+
+	return handler.NewLcdHandler(
+		chain,
+		allowPaths,
+		lcdDenyPaths,
+		lcdWritePaths,
+		p,
+		app,
+	)
+}
+
+// initGrpcHandler registers the grpc proxy of a chain when its grpc upstream is configured
+func initGrpcHandler(app *app.App, name string, chain constant.Chain, upstream config.CosmosUpstream, modules ...string) error {
+	if upstream.Grpc == "" {
+		return nil
+	}
+
+	p, err := proxy.NewGrpcProxy(proxy.GrpcProxyConfig{
+		Upstream: upstream.Grpc,
+		Tls:      upstream.GrpcTls,
+	})
+	if err != nil {
+		return err
+	}
+
+	allowMethods := []string{"/grpc.reflection.*"}
+	for _, module := range append(modules, cosmosModules...) {
+		allowMethods = append(allowMethods, "/"+module+".*")
+	}
+
+	app.GrpcHandlers[name] = handler.NewGrpcHandler(
+		chain,
+		upstream.GrpcListen,
+		allowMethods,
+		grpcDenyMethods,
+		p,
+		app,
+	)
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package initapp
+
+import (
+	"fmt"
+	"net/http"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/httpclient"
+	"starnet/chain-api/pkg/upstreamauth"
+
+	"github.com/gorilla/websocket"
+)
+
+// newUpstreamClient returns the http client and the websocket dialer of the upstreams of a chain after checking
+// their credentials, a jwt secret which can not be read would send the calls unauthenticated
+func newUpstreamClient(cfg config.HttpClientConfig, auth config.UpstreamAuth, pools map[string]config.UpstreamPool) (*http.Client, *websocket.Dialer, error) {
+	if err := upstreamauth.Validate(auth); err != nil {
+		return nil, nil, err
+	}
+	for name, pool := range pools {
+		if err := upstreamauth.Validate(pool.Auth); err != nil {
+			return nil, nil, fmt.Errorf("pool %s: %w", name, err)
+		}
+	}
+	client, err := httpclient.New(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	wsDialer, err := httpclient.NewWsDialer(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, wsDialer, nil
+}
@@ -20,6 +20,12 @@ var ParseError = &JsonRpcErr{
 	Message: "Parse error",
 }
 
+// RequestTooLargeErr answers a request body over the size limit
+var RequestTooLargeErr = &JsonRpcErr{
+	Code:    -32600,
+	Message: "Request too large",
+}
+
 func NewInternalServerError(id interface{}) *JsonRpcErr {
 	return &JsonRpcErr{
 		ID:      id,
@@ -36,6 +42,14 @@ func NewUnsupportedMethodError(id interface{}) *JsonRpcErr {
 	}
 }
 
+func NewInvalidParamsError(id interface{}, message string) *JsonRpcErr {
+	return &JsonRpcErr{
+		ID:      id,
+		Code:    -32602,
+		Message: message,
+	}
+}
+
 type JsonRpcErr struct {
 	ID      interface{}
 	Code    int
@@ -0,0 +1,126 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Evm block tags, see https://ethereum.org/en/developers/docs/apis/json-rpc/#default-block
+const (
+	EvmBlockEarliest  = "earliest"
+	EvmBlockLatest    = "latest"
+	EvmBlockPending   = "pending"
+	EvmBlockSafe      = "safe"
+	EvmBlockFinalized = "finalized"
+)
+
+// evmBlockParamIndex is the position of the block param of the calls reading state at a block
+var evmBlockParamIndex = map[string]int{
+	"eth_getBlockByNumber":                    0,
+	"eth_getBlockTransactionCountByNumber":    0,
+	"eth_getTransactionByBlockNumberAndIndex": 0,
+	"eth_getUncleCountByBlockNumber":          0,
+	"eth_getUncleByBlockNumberAndIndex":       0,
+	"eth_getBlockReceipts":                    0,
+	"debug_traceBlockByNumber":                0,
+	"trace_block":                             0,
+	"eth_getLogs":                             0,
+	"eth_getBalance":                          1,
+	"eth_getCode":                             1,
+	"eth_getTransactionCount":                 1,
+	"eth_call":                                1,
+	"eth_estimateGas":                         1,
+	"eth_createAccessList":                    1,
+	"eth_feeHistory":                          1,
+	"debug_traceCall":                         1,
+	"eth_getStorageAt":                        2,
+	"eth_getProof":                            2,
+}
+
+// EvmBlock is the block a call reads, one of Tag, Hash or Number is set
+type EvmBlock struct {
+	Tag    string
+	Hash   string
+	Number uint64
+}
+
+// EvmBlockParam returns the block param of a call, ok is false for calls without one. An omitted block
+// param is latest, an EIP-1898 object returns its blockNumber or blockHash and a log filter its fromBlock.
+func EvmBlockParam(call *JsonRpcSingleRequest) (block EvmBlock, ok bool) {
+	index, ok := evmBlockParamIndex[call.Method]
+	if !ok {
+		return block, false
+	}
+	var params []json.RawMessage
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		return block, false
+	}
+	if index >= len(params) {
+		return EvmBlock{Tag: EvmBlockLatest}, true
+	}
+
+	var s string
+	if err := json.Unmarshal(params[index], &s); err != nil {
+		var object struct {
+			BlockNumber string `json:"blockNumber"`
+			BlockHash   string `json:"blockHash"`
+			FromBlock   string `json:"fromBlock"` // eth_getLogs filter
+		}
+		if err = json.Unmarshal(params[index], &object); err != nil {
+			return block, false
+		}
+		if object.BlockHash != "" {
+			return EvmBlock{Hash: object.BlockHash}, true
+		}
+		s = object.BlockNumber
+		if s == "" {
+			s = object.FromBlock
+		}
+	}
+
+	if !strings.HasPrefix(s, "0x") {
+		if s == "" {
+			s = EvmBlockLatest
+		}
+		return EvmBlock{Tag: s}, true
+	}
+	number, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return block, false
+	}
+	return EvmBlock{Number: number}, true
+}
+
+// PinEvmLatest replaces the latest or omitted block param of a call with number, it reports whether the call changed
+func PinEvmLatest(call *JsonRpcSingleRequest, number uint64) bool {
+	index, ok := evmBlockParamIndex[call.Method]
+	if !ok {
+		return false
+	}
+	var params []json.RawMessage
+	if len(call.Params) > 0 {
+		if err := json.Unmarshal(call.Params, &params); err != nil {
+			return false
+		}
+	}
+	block, _ := json.Marshal("0x" + strconv.FormatUint(number, 16))
+	switch {
+	case index > len(params):
+		return false
+	case index == len(params):
+		params = append(params, block)
+	default:
+		var s string
+		if err := json.Unmarshal(params[index], &s); err != nil || s != EvmBlockLatest {
+			return false
+		}
+		params[index] = block
+	}
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return false
+	}
+	call.Params = rawParams
+	return true
+}
@@ -0,0 +1,56 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEvmBlockParam(t *testing.T) {
+	tests := []struct {
+		method string
+		params string
+		block  EvmBlock
+		ok     bool
+	}{
+		{"eth_getBalance", `["0x01","0x10"]`, EvmBlock{Number: 16}, true},
+		{"eth_getBalance", `["0x01"]`, EvmBlock{Tag: EvmBlockLatest}, true},
+		{"eth_getStorageAt", `["0x01","0x0","earliest"]`, EvmBlock{Tag: EvmBlockEarliest}, true},
+		{"eth_call", `[{"to":"0x01"},{"blockHash":"0xabc"}]`, EvmBlock{Hash: "0xabc"}, true},
+		{"eth_call", `[{"to":"0x01"},{"blockNumber":"0x2"}]`, EvmBlock{Number: 2}, true},
+		{"eth_getBlockByNumber", `["pending",false]`, EvmBlock{Tag: EvmBlockPending}, true},
+		{"eth_getBlockByNumber", `["0xzz",false]`, EvmBlock{}, false},
+		{"eth_getLogs", `[{"fromBlock":"0x5","toBlock":"latest"}]`, EvmBlock{Number: 5}, true},
+		{"eth_getLogs", `[{"address":"0x01"}]`, EvmBlock{Tag: EvmBlockLatest}, true},
+		{"eth_chainId", `[]`, EvmBlock{}, false},
+	}
+	for _, test := range tests {
+		block, ok := EvmBlockParam(&JsonRpcSingleRequest{Method: test.method, Params: json.RawMessage(test.params)})
+		if block != test.block || ok != test.ok {
+			t.Errorf("%s %s: expected %+v %v, got %+v %v", test.method, test.params, test.block, test.ok, block, ok)
+		}
+	}
+}
+
+func TestPinEvmLatest(t *testing.T) {
+	tests := []struct {
+		method  string
+		params  string
+		pinned  string
+		changed bool
+	}{
+		{"eth_getBalance", `["0x01","latest"]`, `["0x01","0x1f"]`, true},
+		{"eth_call", `[{"to":"0x01"}]`, `[{"to":"0x01"},"0x1f"]`, true},
+		{"eth_getBalance", `["0x01","0x10"]`, `["0x01","0x10"]`, false},
+		{"eth_getBalance", `["0x01","pending"]`, `["0x01","pending"]`, false},
+		{"eth_getStorageAt", `["0x01"]`, `["0x01"]`, false},
+		{"eth_getLogs", `[{"fromBlock":"latest"}]`, `[{"fromBlock":"latest"}]`, false},
+		{"eth_chainId", `[]`, `[]`, false},
+	}
+	for _, test := range tests {
+		call := &JsonRpcSingleRequest{Method: test.method, Params: json.RawMessage(test.params)}
+		changed := PinEvmLatest(call, 31)
+		if changed != test.changed || string(call.Params) != test.pinned {
+			t.Errorf("%s %s: expected %s %v, got %s %v", test.method, test.params, test.pinned, test.changed, call.Params, changed)
+		}
+	}
+}
@@ -8,14 +8,23 @@ import (
 // https://www.jsonrpc.org/specification#compatibility
 
 const (
-	RequestTypeGeth   = 1
-	RequestTypeErigon = 2
+	RequestTypeGeth    = 1
+	RequestTypeErigon  = 2
+	RequestTypePrivate = 3 // sent to the private transaction relay
 )
 
 type JsonRpcRequest struct {
 	batchCall   []JsonRpcSingleRequest
 	singleCall  *JsonRpcSingleRequest
-	RequestType uint8 // 1-geth 2-erigon
+	RequestType uint8 // 1-geth 2-erigon 3-private
+}
+
+func NewSingleRequest(call *JsonRpcSingleRequest) *JsonRpcRequest {
+	return &JsonRpcRequest{singleCall: call}
+}
+
+func NewBatchRequest(calls []JsonRpcSingleRequest) *JsonRpcRequest {
+	return &JsonRpcRequest{batchCall: calls}
 }
 
 func (r *JsonRpcRequest) IsBatchCall() bool {
@@ -34,6 +43,30 @@ func (r *JsonRpcRequest) Cost() int {
 	return n
 }
 
+// WeightedCost is like Cost but charges each call by its method weight, methods missing from costs count as 1.
+func (r *JsonRpcRequest) WeightedCost(costs map[string]int) int {
+	if len(costs) == 0 {
+		return r.Cost()
+	}
+	weight := func(method string) int {
+		if c, ok := costs[method]; ok && c > 0 {
+			return c
+		}
+		return 1
+	}
+	if r.singleCall != nil {
+		return weight(r.singleCall.Method)
+	}
+	if len(r.batchCall) == 0 {
+		return 1
+	}
+	n := 0
+	for _, call := range r.batchCall {
+		n += weight(call.Method)
+	}
+	return n
+}
+
 func (r *JsonRpcRequest) GetBatchCall() []JsonRpcSingleRequest {
 	return r.batchCall
 }
@@ -0,0 +1,65 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+)
+
+// Solana commitment levels, see https://solana.com/docs/rpc#configuring-state-commitment
+const (
+	SolanaCommitmentProcessed = "processed"
+	SolanaCommitmentConfirmed = "confirmed"
+	SolanaCommitmentFinalized = "finalized"
+)
+
+// SolanaCommitment returns the commitment level of a Solana call.
+// The config object is always the last positional param, and nodes use finalized when it is omitted.
+func SolanaCommitment(params json.RawMessage) string {
+	cfg := solanaConfigObject(params)
+	if cfg == nil {
+		return SolanaCommitmentFinalized
+	}
+	var v struct {
+		Commitment string `json:"commitment"`
+	}
+	if err := json.Unmarshal(cfg, &v); err != nil || v.Commitment == "" {
+		return SolanaCommitmentFinalized
+	}
+	switch v.Commitment {
+	// deprecated commitment levels
+	case "recent", "single", "singleGossip":
+		return SolanaCommitmentProcessed
+	case "max", "root":
+		return SolanaCommitmentFinalized
+	}
+	return v.Commitment
+}
+
+// ValidateSolanaRequest rejects calls which are valid for the node but too expensive to serve,
+// getProgramAccounts without filters scans every account owned by the program.
+func ValidateSolanaRequest(req *JsonRpcSingleRequest) *JsonRpcErr {
+	if req.Method != "getProgramAccounts" {
+		return nil
+	}
+	cfg := solanaConfigObject(req.Params)
+	if cfg != nil {
+		var v struct {
+			Filters []json.RawMessage `json:"filters"`
+		}
+		if err := json.Unmarshal(cfg, &v); err == nil && len(v.Filters) > 0 {
+			return nil
+		}
+	}
+	return NewInvalidParamsError(req.ID, "getProgramAccounts requires filters")
+}
+
+func solanaConfigObject(params json.RawMessage) json.RawMessage {
+	var list []json.RawMessage
+	if err := json.Unmarshal(params, &list); err != nil || len(list) == 0 {
+		return nil
+	}
+	last := list[len(list)-1]
+	if len(last) == 0 || last[0] != '{' {
+		return nil
+	}
+	return last
+}
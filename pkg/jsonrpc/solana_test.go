@@ -0,0 +1,45 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSolanaCommitment(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{``, SolanaCommitmentFinalized},
+		{`[430]`, SolanaCommitmentFinalized},
+		{`[430, {"encoding": "json"}]`, SolanaCommitmentFinalized},
+		{`[430, {"commitment": "confirmed"}]`, SolanaCommitmentConfirmed},
+		{`["sig", {"commitment": "processed", "encoding": "json"}]`, SolanaCommitmentProcessed},
+		{`[{"commitment": "recent"}]`, SolanaCommitmentProcessed},
+	}
+	for _, test := range tests {
+		if got := SolanaCommitment(json.RawMessage(test.params)); got != test.want {
+			t.Errorf("SolanaCommitment(%s) = %s, want %s", test.params, got, test.want)
+		}
+	}
+}
+
+func TestValidateSolanaRequest(t *testing.T) {
+	tests := []struct {
+		method string
+		params string
+		ok     bool
+	}{
+		{"getBalance", `["addr"]`, true},
+		{"getProgramAccounts", `["program"]`, false},
+		{"getProgramAccounts", `["program", {"encoding": "base64"}]`, false},
+		{"getProgramAccounts", `["program", {"filters": []}]`, false},
+		{"getProgramAccounts", `["program", {"filters": [{"dataSize": 165}]}]`, true},
+	}
+	for _, test := range tests {
+		req := &JsonRpcSingleRequest{Method: test.method, Params: json.RawMessage(test.params)}
+		if err := ValidateSolanaRequest(req); (err == nil) != test.ok {
+			t.Errorf("ValidateSolanaRequest(%s %s) = %v, want ok %v", test.method, test.params, err, test.ok)
+		}
+	}
+}
@@ -1,6 +1,84 @@
 package jsonrpc
 
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// TenderMintRequest is a tendermint rpc call, e.g. GET /block?height=5 or the json-rpc call {"method":"block","params":{"height":"5"}}
 type TenderMintRequest struct {
-	Path     string
-	URLQuery string
+	Path  string // rpc method
+	Query url.Values
+}
+
+// ParseTenderMintRequest parses an uri style call, rawQuery is the undecoded query without the leading ?
+func ParseTenderMintRequest(path, rawQuery string) (*TenderMintRequest, error) {
+	path = strings.Trim(path, "/")
+	if path == "" || strings.Contains(path, "/") {
+		return nil, fmt.Errorf("invalid tendermint method %q", path)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	return &TenderMintRequest{Path: path, Query: query}, nil
+}
+
+// NewTenderMintRequestFromCall converts named json-rpc params to query values, positional params are dropped
+func NewTenderMintRequestFromCall(call *JsonRpcSingleRequest) *TenderMintRequest {
+	req := &TenderMintRequest{Path: call.Method, Query: url.Values{}}
+	params := map[string]json.RawMessage{}
+	if err := json.Unmarshal(call.Params, &params); err != nil {
+		return req
+	}
+	for name, value := range params {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			s = string(value)
+		}
+		req.Query.Set(name, s)
+	}
+	return req
+}
+
+// URLQuery returns the encoded query with the leading ?, params are sorted by name
+func (r *TenderMintRequest) URLQuery() string {
+	if len(r.Query) == 0 {
+		return ""
+	}
+	return "?" + r.Query.Encode()
+}
+
+// Height returns the height param of the call, 0 means the latest block
+func (r *TenderMintRequest) Height() int64 {
+	height, err := strconv.ParseInt(strings.Trim(r.Query.Get("height"), `"`), 10, 64)
+	if err != nil || height < 0 {
+		return 0
+	}
+	return height
+}
+
+// JsonRpcCall converts an uri style call to the json-rpc call tendermint answers the same way, quoted values are
+// unquoted and 0x hex bytes are base64 encoded. The id is -1 like tendermint uses for uri calls.
+func (r *TenderMintRequest) JsonRpcCall() *JsonRpcSingleRequest {
+	params := make(map[string]string, len(r.Query))
+	for name := range r.Query {
+		value := r.Query.Get(name)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if strings.HasPrefix(value, "0x") {
+			if raw, err := hex.DecodeString(value[2:]); err == nil {
+				value = base64.StdEncoding.EncodeToString(raw)
+			}
+		}
+		params[name] = value
+	}
+	rawParams, _ := json.Marshal(params)
+	var id interface{} = -1
+	return &JsonRpcSingleRequest{ID: &id, JsonRpcVersion: "2.0", Method: r.Path, Params: rawParams}
 }
@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseTenderMintRequest(t *testing.T) {
+	tests := []struct {
+		path     string
+		rawQuery string
+		query    string
+		height   int64
+	}{
+		{"block", "height=5", "?height=5", 5},
+		{"/block/", `height="5"`, "?height=%225%22", 5},
+		{"status", "", "", 0},
+		{"abci_query", `path="/store/acc/key"&data=0x01&height=10`, "?data=0x01&height=10&path=%22%2Fstore%2Facc%2Fkey%22", 10},
+		{"tx_search", `query="tx.height=5"&prove=true`, "?prove=true&query=%22tx.height%3D5%22", 0},
+	}
+	for _, test := range tests {
+		req, err := ParseTenderMintRequest(test.path, test.rawQuery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.URLQuery() != test.query {
+			t.Errorf("%s: expected query %s, got %s", test.path, test.query, req.URLQuery())
+		}
+		if req.Height() != test.height {
+			t.Errorf("%s: expected height %d, got %d", test.path, test.height, req.Height())
+		}
+	}
+
+	for _, path := range []string{"", "/", "block/extra"} {
+		if _, err := ParseTenderMintRequest(path, ""); err == nil {
+			t.Errorf("expected error for path %q", path)
+		}
+	}
+}
+
+func TestNewTenderMintRequestFromCall(t *testing.T) {
+	req := NewTenderMintRequestFromCall(&JsonRpcSingleRequest{
+		Method: "block",
+		Params: json.RawMessage(`{"height":"12"}`),
+	})
+	if req.Path != "block" || req.Height() != 12 {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	req = NewTenderMintRequestFromCall(&JsonRpcSingleRequest{
+		Method: "validators",
+		Params: json.RawMessage(`{"height":7,"page":"1"}`),
+	})
+	if req.Height() != 7 || req.Query.Get("page") != "1" {
+		t.Errorf("unexpected request %+v", req)
+	}
+
+	req = NewTenderMintRequestFromCall(&JsonRpcSingleRequest{Method: "status"})
+	if req.Height() != 0 || req.URLQuery() != "" {
+		t.Errorf("unexpected request %+v", req)
+	}
+}
+
+func TestTenderMintRequestJsonRpcCall(t *testing.T) {
+	req, err := ParseTenderMintRequest("broadcast_tx_sync", `tx=0x68656c6c6f`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := req.JsonRpcCall()
+	if call.Method != "broadcast_tx_sync" || string(call.Params) != `{"tx":"aGVsbG8="}` {
+		t.Errorf("unexpected call %s %s", call.Method, call.Params)
+	}
+
+	req, err = ParseTenderMintRequest("broadcast_tx_async", `tx="aGVsbG8="`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call = req.JsonRpcCall(); string(call.Params) != `{"tx":"aGVsbG8="}` {
+		t.Errorf("unexpected params %s", call.Params)
+	}
+}
@@ -0,0 +1,53 @@
+package jsonrpc
+
+// writeMethods change node state or read state kept by a single node, they must be sent once to one node
+var writeMethods = map[string]bool{
+	"eth_sendRawTransaction":          true,
+	"eth_sendTransaction":             true,
+	"eth_sendBundle":                  true,
+	"eth_sendPrivateTransaction":      true,
+	"eth_sign":                        true,
+	"eth_signTransaction":             true,
+	"eth_newFilter":                   true,
+	"eth_newBlockFilter":              true,
+	"eth_newPendingTransactionFilter": true,
+	"eth_uninstallFilter":             true,
+	"eth_getFilterChanges":            true,
+	"eth_subscribe":                   true,
+	"eth_unsubscribe":                 true,
+	"sendTransaction":                 true, // solana
+	"sendrawtransaction":              true, // utxo
+	"author_submitExtrinsic":          true, // substrate
+	"broadcast_tx_sync":               true, // tendermint
+	"broadcast_tx_async":              true,
+	"broadcast_tx_commit":             true,
+}
+
+// IsWriteMethod reports whether a call to method must not be retried or hedged on another node
+func IsWriteMethod(method string) bool {
+	return writeMethods[method]
+}
+
+// HasWriteCall reports whether a call of the request is a write method or one of extraWriteMethods
+func (r *JsonRpcRequest) HasWriteCall(extraWriteMethods []string) bool {
+	isWrite := func(method string) bool {
+		if IsWriteMethod(method) {
+			return true
+		}
+		for _, m := range extraWriteMethods {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	if r.singleCall != nil {
+		return isWrite(r.singleCall.Method)
+	}
+	for _, call := range r.batchCall {
+		if isWrite(call.Method) {
+			return true
+		}
+	}
+	return false
+}
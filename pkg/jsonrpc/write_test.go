@@ -0,0 +1,21 @@
+package jsonrpc
+
+import "testing"
+
+func TestHasWriteCall(t *testing.T) {
+	tests := []struct {
+		req   *JsonRpcRequest
+		write bool
+	}{
+		{NewSingleRequest(&JsonRpcSingleRequest{Method: "eth_call"}), false},
+		{NewSingleRequest(&JsonRpcSingleRequest{Method: "eth_sendRawTransaction"}), true},
+		{NewSingleRequest(&JsonRpcSingleRequest{Method: "custom_submit"}), true},
+		{NewBatchRequest([]JsonRpcSingleRequest{{Method: "eth_call"}, {Method: "eth_getBalance"}}), false},
+		{NewBatchRequest([]JsonRpcSingleRequest{{Method: "eth_call"}, {Method: "eth_getFilterChanges"}}), true},
+	}
+	for i, test := range tests {
+		if write := test.req.HasWriteCall([]string{"custom_submit"}); write != test.write {
+			t.Errorf("%d: expected %v, got %v", i, test.write, write)
+		}
+	}
+}
@@ -28,6 +28,69 @@ func (m *ErrorNumMetric) String() string {
 	return fmt.Sprintf(`error_num{node="%s"} %d`, m.NodeName, m.ErrorNum)
 }
 
+// HeadMetric is a block number followed by the head tracker, Kind is latest, safe or finalized
+type HeadMetric struct {
+	Kind   string
+	Number uint64
+}
+
+func (m *HeadMetric) String() string {
+	return fmt.Sprintf(`head_block{kind="%s"} %d`, m.Kind, m.Number)
+}
+
+// PushHeadMetrics pushes the head numbers and the number of reorgs seen since start
+func PushHeadMetrics(networkName string, metrics []HeadMetric, reorgs int) {
+	body := ""
+	for _, metric := range metrics {
+		body += metric.String() + "\n"
+	}
+	body += fmt.Sprintf("reorg_num %d\n", reorgs)
+	if err := pushBody(networkName, body); err != nil {
+		fmt.Printf("failed to push metrics to pushgateway: %v", err)
+	}
+}
+
+// QuorumMetric is the number of quorum calls to Method whose nodes disagreed since start
+type QuorumMetric struct {
+	Method     string
+	Mismatches int64
+}
+
+func (m *QuorumMetric) String() string {
+	return fmt.Sprintf(`quorum_mismatch_num{method="%s"} %d`, m.Method, m.Mismatches)
+}
+
+func PushQuorumMetrics(networkName string, metrics []QuorumMetric) {
+	body := ""
+	for _, metric := range metrics {
+		body += metric.String() + "\n"
+	}
+	if err := pushBody(networkName, body); err != nil {
+		fmt.Printf("failed to push metrics to pushgateway: %v", err)
+	}
+}
+
+// BreakerMetric is the circuit breaker state of a node endpoint, 0 closed, 1 open and 2 half-open
+type BreakerMetric struct {
+	NodeName string
+	Endpoint string
+	State    int
+}
+
+func (m *BreakerMetric) String() string {
+	return fmt.Sprintf(`breaker_state{node="%s",endpoint="%s"} %d`, m.NodeName, m.Endpoint, m.State)
+}
+
+func PushBreakerMetrics(networkName string, metrics []BreakerMetric) {
+	body := ""
+	for _, metric := range metrics {
+		body += metric.String() + "\n"
+	}
+	if err := pushBody(networkName, body); err != nil {
+		fmt.Printf("failed to push metrics to pushgateway: %v", err)
+	}
+}
+
 // pushBody sends a Prometheus exposition-format body to the Pushgateway in one HTTP request.
 // The body may contain multiple metrics, one per line (e.g. "metric_name{label=\"val\"} 123").
 func pushBody(networkName string, body string) error {
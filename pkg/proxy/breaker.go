@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"starnet/chain-api/pkg/breaker"
+	"starnet/chain-api/pkg/prometheus"
+)
+
+// breakerNames name the http upstreams in the breaker metrics, the urls may hold credentials
+func (cfg *JsonRpcProxyConfig) breakerNames() map[string]string {
+	names := map[string]string{}
+	add := func(url, name string) {
+		if url != "" {
+			if _, ok := names[url]; !ok {
+				names[url] = name
+			}
+		}
+	}
+	add(cfg.HttpUpstream, "upstream")
+	add(cfg.HttpPrivateUpstream, "private")
+	for name, pool := range cfg.Pools {
+		add(pool.Http, "pool_"+name)
+	}
+	for i, url := range cfg.HedgeUpstreams {
+		add(url, fmt.Sprintf("hedge_%d", i))
+	}
+	return names
+}
+
+// recordCall feeds the breaker of the upstream, 5xx responses count as failed
+func (p *JsonRpcProxy) recordCall(url string, res *http.Response, err error, latency time.Duration) {
+	if err == nil && res.StatusCode >= http.StatusInternalServerError {
+		err = fmt.Errorf("status %d", res.StatusCode)
+	}
+	p.breakers[url].Record(err, latency)
+}
+
+// hedgeUpstream returns the next hedge upstream whose breaker is not open, "" when there is none
+func (p *JsonRpcProxy) hedgeUpstream() string {
+	n := uint64(len(p.cfg.HedgeUpstreams))
+	start := p.hedgeNext.Add(1)
+	for i := uint64(0); i < n; i++ {
+		url := p.cfg.HedgeUpstreams[(start+i)%n]
+		if p.breakers[url].Ready() {
+			return url
+		}
+	}
+	return ""
+}
+
+func (p *JsonRpcProxy) reportBreakers() {
+	names := p.cfg.breakerNames()
+	for ; ; time.Sleep(time.Minute) {
+		metrics := make([]prometheus.BreakerMetric, 0, len(names))
+		for url, name := range names {
+			metrics = append(metrics, prometheus.BreakerMetric{NodeName: name, Endpoint: "http", State: int(p.breakers[url].State())})
+		}
+		prometheus.PushBreakerMetrics(p.cfg.ChainName, metrics)
+	}
+}
+
+func newBreakers(cfg *JsonRpcProxyConfig) map[string]*breaker.Breaker {
+	breakers := make(map[string]*breaker.Breaker)
+	for url := range cfg.breakerNames() {
+		breakers[url] = breaker.New(breaker.Config{})
+	}
+	return breakers
+}
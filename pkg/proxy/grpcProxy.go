@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcApiKeyHeader is the metadata key carrying the api key of grpc clients
+const GrpcApiKeyHeader = "x-api-key"
+
+// grpcWebForwardHeaders are the grpc-web request headers sent upstream as metadata
+var grpcWebForwardHeaders = []string{
+	"x-cosmos-block-height",
+}
+
+type GrpcProxyConfig struct {
+	Upstream string // host:port
+	Tls      bool
+}
+
+// grpcFrame is a grpc message which is never decoded
+type grpcFrame struct {
+	payload []byte
+}
+
+// RawCodec passes grpc messages through as bytes, so no generated types are needed to proxy a service
+type RawCodec struct{}
+
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	frame, ok := v.(*grpcFrame)
+	if !ok {
+		return nil, fmt.Errorf("unexpected grpc message type %T", v)
+	}
+	return frame.payload, nil
+}
+
+func (RawCodec) Unmarshal(data []byte, v interface{}) error {
+	frame, ok := v.(*grpcFrame)
+	if !ok {
+		return fmt.Errorf("unexpected grpc message type %T", v)
+	}
+	frame.payload = append([]byte(nil), data...)
+	return nil
+}
+
+func (RawCodec) Name() string {
+	return "proto"
+}
+
+var grpcProxyStreamDesc = &grpc.StreamDesc{
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// GrpcProxy forwards any grpc method to a single upstream
+type GrpcProxy struct {
+	conn *grpc.ClientConn
+}
+
+func NewGrpcProxy(cfg GrpcProxyConfig) (*GrpcProxy, error) {
+	creds := insecure.NewCredentials()
+	if cfg.Tls {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+	conn, err := grpc.NewClient(cfg.Upstream, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create grpc upstream client")
+	}
+	return &GrpcProxy{conn: conn}, nil
+}
+
+func (p *GrpcProxy) Close() error {
+	return p.conn.Close()
+}
+
+// Forward relays the messages of stream to the upstream method and copies back headers, messages and trailers
+func (p *GrpcProxy) Forward(stream grpc.ServerStream, fullMethod string) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	md = md.Copy()
+	md.Delete(GrpcApiKeyHeader)
+
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(stream.Context(), md))
+	defer cancel()
+
+	upstream, err := p.conn.NewStream(ctx, grpcProxyStreamDesc, fullMethod, grpc.ForceCodec(RawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			frame := &grpcFrame{}
+			if err := stream.RecvMsg(frame); err != nil {
+				if err == io.EOF {
+					upstream.CloseSend()
+				} else {
+					cancel()
+				}
+				return
+			}
+			if err := upstream.SendMsg(frame); err != nil {
+				// the real error is returned by upstream.RecvMsg
+				return
+			}
+		}
+	}()
+
+	if header, err := upstream.Header(); err == nil {
+		if err := stream.SendHeader(header); err != nil {
+			return err
+		}
+	}
+	for {
+		frame := &grpcFrame{}
+		if err := upstream.RecvMsg(frame); err != nil {
+			stream.SetTrailer(upstream.Trailer())
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(frame); err != nil {
+			return err
+		}
+	}
+}
+
+// ForwardGrpcWeb calls the upstream with the messages of a binary grpc-web request body and writes a grpc-web
+// response, the grpc status is always sent in the trailer frame
+func (p *GrpcProxy) ForwardGrpcWeb(ctx context.Context, logger *zap.Logger, w http.ResponseWriter, r *http.Request, fullMethod string, body []byte) {
+	md := metadata.MD{}
+	for _, name := range grpcWebForwardHeaders {
+		if value := r.Header.Get(name); value != "" {
+			md.Set(name, value)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/grpc-web+proto")
+	w.WriteHeader(http.StatusOK)
+
+	err := p.callGrpcWeb(metadata.NewOutgoingContext(ctx, md), w, fullMethod, body)
+	if err != nil {
+		logger.Debug("grpc-web call failed", zap.String("method", fullMethod), zap.Error(err))
+	}
+	WriteGrpcWebTrailer(w, status.Convert(err))
+}
+
+func (p *GrpcProxy) callGrpcWeb(ctx context.Context, w http.ResponseWriter, fullMethod string, body []byte) error {
+	frames, err := readGrpcWebFrames(body)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	upstream, err := p.conn.NewStream(ctx, grpcProxyStreamDesc, fullMethod, grpc.ForceCodec(RawCodec{}))
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if err := upstream.SendMsg(frame); err != nil {
+			break
+		}
+	}
+	upstream.CloseSend()
+
+	for {
+		frame := &grpcFrame{}
+		if err := upstream.RecvMsg(frame); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		writeGrpcWebFrame(w, 0, frame.payload)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+// readGrpcWebFrames splits a grpc-web body into its length prefixed messages
+func readGrpcWebFrames(body []byte) ([]*grpcFrame, error) {
+	var frames []*grpcFrame
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, errors.New("truncated grpc-web frame header")
+		}
+		if body[0] != 0 {
+			return nil, errors.New("compressed grpc-web frames are not supported")
+		}
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			return nil, errors.New("truncated grpc-web frame")
+		}
+		frames = append(frames, &grpcFrame{payload: body[5 : 5+size]})
+		body = body[5+size:]
+	}
+	return frames, nil
+}
+
+func writeGrpcWebFrame(w io.Writer, flag byte, payload []byte) {
+	header := [5]byte{flag}
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	w.Write(header[:])
+	w.Write(payload)
+}
+
+// WriteGrpcWebTrailer writes the grpc-web trailer frame holding the call status
+func WriteGrpcWebTrailer(w io.Writer, st *status.Status) {
+	trailer := bytes.Buffer{}
+	fmt.Fprintf(&trailer, "grpc-status:%d\r\n", st.Code())
+	if st.Message() != "" {
+		fmt.Fprintf(&trailer, "grpc-message:%s\r\n", strings.ReplaceAll(st.Message(), "\r\n", " "))
+	}
+	writeGrpcWebFrame(w, 0x80, trailer.Bytes())
+}
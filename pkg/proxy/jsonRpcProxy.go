@@ -17,8 +17,13 @@ import (
 	"sync/atomic"
 	"time"
 
+	"starnet/chain-api/config"
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/breaker"
+	"starnet/chain-api/pkg/compression"
+	"starnet/chain-api/pkg/hedge"
 	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/upstreamauth"
 	"starnet/chain-api/pkg/utils"
 
 	"github.com/go-redis/redis/v8"
@@ -38,49 +43,185 @@ type UpstreamJsonRpcResponse struct {
 type request struct {
 	*jsonrpc.JsonRpcRequest
 	*jsonrpc.TenderMintRequest
-	ID       interface{} `json:"id"` // overwrite id while sending to upstream
-	cacheKey *string
-	cacheFn  func(request *request, result []byte) error
-	ctx      context.Context
-	logger   *zap.Logger
+	ID        interface{} `json:"id"` // overwrite id while sending to upstream
+	cacheKey  *string
+	cacheTime time.Duration
+	cacheFn   func(request *request, result []byte) error
+	blockKey  string // see blockCacheKey, "" when a reorg can not change the result
+	ctx       context.Context
+	logger    *zap.Logger
 }
 
 type RespData struct {
 	Data          []byte
 	RequestMethod string
 	Subscription  bool
+
+	// BatchCalls are the calls of a batch response, its responses are matched to them by id
+	BatchCalls []jsonrpc.JsonRpcSingleRequest
 }
 
 type JsonRpcProxyConfig struct {
 	HttpUpstream string
 	WsUpstream   string
+	Auth         config.UpstreamAuth // of HttpUpstream and WsUpstream, the pools have their own
+
+	// Routes send matching calls to Pools, other calls and routes to unknown pools use the upstream above
+	Pools  map[string]config.UpstreamPool
+	Routes []config.UpstreamRoute
+
+	HttpPrivateUpstream string // private transaction relay, also used for private calls sent over ws
 
-	HttpErigonStream string
-	WsErigonUpstream string
+	// HedgeUpstreams mirror HttpUpstream, a read call it has not answered within its p95 latency is also sent to one
+	HedgeUpstreams []string
 
 	HttpClient       *http.Client
+	WsDialer         *websocket.Dialer // of WsUpstream and the pool websockets, defaults to websocket.DefaultDialer
 	CacheTime        time.Duration
 	ChainID          uint8
+	ChainName        string // names the breaker metrics
 	CacheableMethods []string
+
+	// CacheTimeFn overrides CacheTime for a single cacheable call, a zero duration disables caching of the call
+	CacheTimeFn func(req *jsonrpc.JsonRpcSingleRequest) time.Duration
+
+	// TendermintCacheTimeFn is CacheTimeFn for tendermint uri calls, json-rpc calls use it too when CacheTimeFn is nil
+	TendermintCacheTimeFn func(req *jsonrpc.TenderMintRequest) time.Duration
+
+	// HeadTracker follows the evm chain for block depth routes, cacheable calls reading a finalized block
+	// by number are cached for finalizedCacheTime, the ones reading a block replaced by a reorg are deleted
+	HeadTracker app.HeadTracker
+
+	// MaxCacheBytes caps the size of a cached response, larger responses are streamed to the client uncached.
+	// Defaults to DefaultMaxCacheBytes
+	MaxCacheBytes int
 }
 
+// DefaultMaxCacheBytes is the default MaxCacheBytes
+const DefaultMaxCacheBytes = 1 << 20
+
+// finalizedCacheTime is the cache time of calls reading a finalized block, they can not change anymore
+const finalizedCacheTime = time.Hour
+
 type JsonRpcProxy struct {
 	rdb        redis.UniversalClient
 	httpClient *http.Client
 	cfg        *JsonRpcProxyConfig
 	requestID  int64
+	latency    *hedge.Latency
+	hedgeNext  atomic.Uint64
+	breakers   map[string]*breaker.Breaker // http upstream url => breaker
+	logger     *zap.Logger
 }
 
 func NewJsonRpcProxy(app *app.App, cfg JsonRpcProxyConfig) *JsonRpcProxy {
+	if cfg.CacheTimeFn == nil && cfg.TendermintCacheTimeFn != nil {
+		tendermintCacheTimeFn := cfg.TendermintCacheTimeFn
+		cfg.CacheTimeFn = func(req *jsonrpc.JsonRpcSingleRequest) time.Duration {
+			return tendermintCacheTimeFn(jsonrpc.NewTenderMintRequestFromCall(req))
+		}
+	}
+
+	if cfg.MaxCacheBytes <= 0 {
+		cfg.MaxCacheBytes = DefaultMaxCacheBytes
+	}
+
 	p := &JsonRpcProxy{
 		rdb:        app.Rdb,
 		httpClient: cfg.HttpClient,
 		cfg:        &cfg,
+		latency:    hedge.NewLatency(),
+		breakers:   newBreakers(&cfg),
+		logger:     app.Logger,
+	}
+	if cfg.HeadTracker != nil {
+		cfg.HeadTracker.OnReorg(func(number uint64) {
+			app.Background.Go(func(ctx context.Context) {
+				p.invalidateReorg(ctx, number)
+			})
+		})
+	}
+	if cfg.ChainName != "" && app.RpcConfig != nil && app.RpcConfig.HealthPushgateway != "" {
+		go p.reportBreakers()
 	}
-
 	return p
 }
 
+// isOldBlock reports whether the call reads a block at least depth blocks behind the head
+func (p *JsonRpcProxy) isOldBlock(call *jsonrpc.JsonRpcSingleRequest, depth uint64) bool {
+	block, ok := jsonrpc.EvmBlockParam(call)
+	switch {
+	case !ok:
+		return false
+	case block.Hash != "":
+		return !p.isRecentHash(block.Hash, depth)
+	case block.Tag == jsonrpc.EvmBlockEarliest:
+		return true
+	case block.Tag != "":
+		return false
+	}
+	if p.cfg.HeadTracker == nil {
+		return false
+	}
+	head := p.cfg.HeadTracker.Latest()
+	return head > 0 && block.Number+depth <= head
+}
+
+// maxHashLookback bounds the blocks searched for a block hash, the head tracker keeps far fewer hashes
+const maxHashLookback = 1024
+
+// isRecentHash reports whether hash is one of the last depth blocks the head tracker knows, e.g. a call pinned
+// to the head by hash. Unknown hashes are treated as old.
+func (p *JsonRpcProxy) isRecentHash(hash string, depth uint64) bool {
+	if p.cfg.HeadTracker == nil {
+		return false
+	}
+	head := p.cfg.HeadTracker.Latest()
+	for i := uint64(0); i < min(depth, maxHashLookback) && i <= head; i++ {
+		if known, ok := p.cfg.HeadTracker.Hash(head - i); ok && strings.EqualFold(known, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFinalizedBlock reports whether the call reads a finalized block by number
+func (p *JsonRpcProxy) isFinalizedBlock(call *jsonrpc.JsonRpcSingleRequest) bool {
+	if p.cfg.HeadTracker == nil {
+		return false
+	}
+	block, ok := jsonrpc.EvmBlockParam(call)
+	if !ok || block.Tag != "" || block.Hash != "" {
+		return false
+	}
+	finalized := p.cfg.HeadTracker.Finalized()
+	return finalized > 0 && block.Number <= finalized
+}
+
+// routeCall returns the pool of the first route matching the method, "" for the chain upstream
+func (p *JsonRpcProxy) routeCall(call *jsonrpc.JsonRpcSingleRequest) string {
+	for _, route := range p.cfg.Routes {
+		if !utils.MatchAnyPath(route.Methods, call.Method) {
+			continue
+		}
+		if route.MinBlockDepth == 0 || p.isOldBlock(call, route.MinBlockDepth) {
+			return route.Pool
+		}
+	}
+	return ""
+}
+
+// route returns the pool of a call, a batch goes to the pool of its first call, see splitBatch
+func (p *JsonRpcProxy) route(req *jsonrpc.JsonRpcRequest) string {
+	if req.IsBatchCall() {
+		if calls := req.GetBatchCall(); len(calls) > 0 {
+			return p.routeCall(&calls[0])
+		}
+		return ""
+	}
+	return p.routeCall(req.GetSingleCall())
+}
+
 func (p *JsonRpcProxy) fromRequest(rawreq *jsonrpc.JsonRpcRequest) (*request, error) {
 	req := request{
 		JsonRpcRequest: rawreq,
@@ -91,7 +232,7 @@ func (p *JsonRpcProxy) fromRequest(rawreq *jsonrpc.JsonRpcRequest) (*request, er
 
 func (p *JsonRpcProxy) HttpProxy(ctx context.Context, logger *zap.Logger, rawreq *jsonrpc.JsonRpcRequest) ([]byte, error) {
 	if rawreq.IsBatchCall() {
-		return p.DoHttpUpstreamCall(rawreq, logger)
+		return p.batchHttpProxy(ctx, logger, rawreq)
 	}
 
 	req, err := p.fromRequest(rawreq)
@@ -110,6 +251,132 @@ func (p *JsonRpcProxy) HttpProxy(ctx context.Context, logger *zap.Logger, rawreq
 	return p.HttpUpstream(req)
 }
 
+// batchHttpProxy answers the cacheable calls of a batch from cache and only sends the others upstream, calls
+// routed to different pools are sent as one batch per pool
+func (p *JsonRpcProxy) batchHttpProxy(ctx context.Context, logger *zap.Logger, rawreq *jsonrpc.JsonRpcRequest) ([]byte, error) {
+	if p.isPlainBatch(rawreq) {
+		return p.DoHttpUpstreamCall(ctx, rawreq, logger)
+	}
+
+	calls := rawreq.GetBatchCall()
+
+	results := make([][]byte, len(calls))
+	reqs := make([]*request, len(calls))
+	var misses []jsonrpc.JsonRpcSingleRequest
+	missIDs := map[string]int{}
+	for i := range calls {
+		req := &request{JsonRpcRequest: jsonrpc.NewSingleRequest(&calls[i]), ctx: ctx, logger: logger}
+		resp, err := p.fromCache(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp != nil {
+			results[i] = resp
+			continue
+		}
+		id, _ := json.Marshal(calls[i].ID)
+		if _, ok := missIDs[string(id)]; ok {
+			// responses can not be matched to calls with the same id
+			return p.DoHttpUpstreamCall(ctx, rawreq, logger)
+		}
+		missIDs[string(id)] = i
+		reqs[i] = req
+		misses = append(misses, calls[i])
+	}
+
+	groups := map[string][]jsonrpc.JsonRpcSingleRequest{}
+	for _, call := range misses {
+		pool := p.routeCall(&call)
+		groups[pool] = append(groups[pool], call)
+	}
+	parts := make([]batchPart, 0, len(groups))
+	for _, group := range groups {
+		parts = append(parts, batchPart{calls: group})
+	}
+	wg := sync.WaitGroup{}
+	for i := range parts {
+		wg.Add(1)
+		go func(part *batchPart) {
+			defer wg.Done()
+			partReq := jsonrpc.NewBatchRequest(part.calls)
+			partReq.RequestType = rawreq.RequestType
+			part.resp, part.err = p.DoHttpUpstreamCall(ctx, partReq, logger)
+		}(&parts[i])
+	}
+	wg.Wait()
+
+	for _, part := range parts {
+		if part.err != nil {
+			return nil, part.err
+		}
+
+		var upstreamResps []UpstreamJsonRpcResponse
+		if err := json.Unmarshal(part.resp, &upstreamResps); err != nil {
+			if len(parts) == 1 {
+				// not a batch response, e.g. an error object for the whole batch
+				return part.resp, nil
+			}
+			for _, call := range part.calls {
+				upstreamResp := UpstreamJsonRpcResponse{JsonRpcVersion: "2.0", Error: upstreamBatchError}
+				if call.ID != nil {
+					upstreamResp.ID = *call.ID
+				}
+				upstreamResps = append(upstreamResps, upstreamResp)
+			}
+		}
+		var err error
+		for _, upstreamResp := range upstreamResps {
+			id, _ := json.Marshal(upstreamResp.ID)
+			i, ok := missIDs[string(id)]
+			if !ok {
+				continue
+			}
+			if results[i], err = json.Marshal(upstreamResp); err != nil {
+				return nil, err
+			}
+			if len(upstreamResp.Error) == 0 && len(upstreamResp.Result) <= p.cfg.MaxCacheBytes {
+				p.cacheResult(reqs[i], upstreamResp.Result)
+			}
+		}
+	}
+
+	buff := bytes.Buffer{}
+	buff.WriteByte('[')
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		if buff.Len() > 1 {
+			buff.WriteByte(',')
+		}
+		buff.Write(result)
+	}
+	buff.WriteByte(']')
+	return buff.Bytes(), nil
+}
+
+// isPlainBatch reports whether a batch has no cacheable call and all its calls go to the same pool, it is sent
+// upstream as is
+func (p *JsonRpcProxy) isPlainBatch(rawreq *jsonrpc.JsonRpcRequest) bool {
+	calls := rawreq.GetBatchCall()
+	for i := range calls {
+		if utils.In(calls[i].Method, p.cfg.CacheableMethods) || p.routeCall(&calls[i]) != p.routeCall(&calls[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// batchPart is the calls of a batch routed to one pool
+type batchPart struct {
+	calls []jsonrpc.JsonRpcSingleRequest
+	resp  []byte
+	err   error
+}
+
+// upstreamBatchError answers the calls of a split batch whose pool rejected the whole batch
+var upstreamBatchError = json.RawMessage(`{"code":-32603,"message":"upstream rejected the batch"}`)
+
 // fromCache get resp form cache
 func (p *JsonRpcProxy) fromCache(req *request) ([]byte, error) {
 	singleReq := req.GetSingleCall()
@@ -118,14 +385,29 @@ func (p *JsonRpcProxy) fromCache(req *request) ([]byte, error) {
 	hash := md5.Sum(singleReq.Params)
 	cacheKey := fmt.Sprintf("rpc:%d:%s:%s", p.cfg.ChainID, singleReq.Method, hex.EncodeToString(hash[:]))
 	cacheable := utils.In(singleReq.Method, p.cfg.CacheableMethods)
+	cacheTime := p.cfg.CacheTime
+	if cacheable && p.cfg.CacheTimeFn != nil {
+		cacheTime = p.cfg.CacheTimeFn(singleReq)
+		cacheable = cacheTime > 0
+	}
+	if cacheable && p.isFinalizedBlock(singleReq) {
+		cacheTime = max(cacheTime, finalizedCacheTime)
+	}
 	if cacheable {
 		req.cacheKey = &cacheKey
+		req.cacheTime = cacheTime
 		req.cacheFn = p.CacheFn
+		if number, ok := p.reorgBlock(singleReq); ok {
+			req.blockKey = p.blockCacheKey(number)
+		}
 
 		res, err := p.rdb.Get(req.ctx, cacheKey).Bytes()
 		if err != nil && !errors.Is(err, redis.Nil) {
 			return nil, err
 		}
+		if res, err = compression.Unpack(res); err != nil {
+			return nil, err
+		}
 
 		// 获取到了内容，则直接返回
 		if len(res) > 0 {
@@ -149,39 +431,172 @@ func (p *JsonRpcProxy) fromCache(req *request) ([]byte, error) {
 }
 
 func (p *JsonRpcProxy) CacheFn(req *request, result []byte) error {
-	return p.rdb.Set(context.TODO(), *req.cacheKey, result, p.cfg.CacheTime).Err()
+	cacheTime := req.cacheTime
+	if cacheTime == 0 {
+		cacheTime = p.cfg.CacheTime
+	}
+	if req.blockKey == "" {
+		return p.rdb.Set(context.TODO(), *req.cacheKey, compression.Pack(result), cacheTime).Err()
+	}
+	_, err := p.rdb.TxPipelined(context.TODO(), func(pipe redis.Pipeliner) error {
+		pipe.Set(context.TODO(), *req.cacheKey, compression.Pack(result), cacheTime)
+		pipe.SAdd(context.TODO(), req.blockKey, *req.cacheKey)
+		pipe.Expire(context.TODO(), req.blockKey, max(cacheTime, finalizedCacheTime))
+		return nil
+	})
+	return err
+}
+
+// isCacheableResult reports whether result holds a value, a null result (e.g. a transaction which is not
+// yet known to the node) must not be cached.
+func isCacheableResult(result json.RawMessage) bool {
+	return result != nil && string(result) != "null"
 }
 
-func (p *JsonRpcProxy) DoHttpUpstreamCall(req *jsonrpc.JsonRpcRequest, logger *zap.Logger) ([]byte, error) {
+// DoHttpUpstreamCall posts req to its upstream and reads the whole response, the call ends with ctx
+func (p *JsonRpcProxy) DoHttpUpstreamCall(ctx context.Context, req *jsonrpc.JsonRpcRequest, logger *zap.Logger) ([]byte, error) {
+	return p.doPoolUpstreamCall(ctx, req, p.route(req), logger)
+}
+
+// doPoolUpstreamCall is DoHttpUpstreamCall posting to the upstream of pool
+func (p *JsonRpcProxy) doPoolUpstreamCall(ctx context.Context, req *jsonrpc.JsonRpcRequest, pool string, logger *zap.Logger) ([]byte, error) {
+	res, err := p.doPoolUpstream(ctx, req, pool, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	buff := bytes.Buffer{}
+	_, err = buff.ReadFrom(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to read response body")
+	}
+	return buff.Bytes(), nil
+}
+
+// doHttpUpstream posts req to its upstream and returns the response with its body unread, the call ends with ctx
+func (p *JsonRpcProxy) doHttpUpstream(ctx context.Context, req *jsonrpc.JsonRpcRequest, logger *zap.Logger) (*http.Response, error) {
+	return p.doPoolUpstream(ctx, req, p.route(req), logger)
+}
+
+// doPoolUpstream is doHttpUpstream posting to the upstream of pool, the chain upstream for unknown pools
+func (p *JsonRpcProxy) doPoolUpstream(ctx context.Context, req *jsonrpc.JsonRpcRequest, pool string, logger *zap.Logger) (*http.Response, error) {
 	rawreq, err := json.Marshal(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to marshal request")
 	}
 
-	var requestRPC string
-	if req.RequestType == jsonrpc.RequestTypeErigon {
-		requestRPC = p.cfg.HttpErigonStream
-	} else {
-		requestRPC = p.cfg.HttpUpstream
+	requestRPC := p.cfg.HttpUpstream
+	if req.RequestType == jsonrpc.RequestTypePrivate {
+		requestRPC = p.cfg.HttpPrivateUpstream
+	} else if pool, ok := p.cfg.Pools[pool]; ok && pool.Http != "" {
+		requestRPC = pool.Http
+	}
+
+	post := func(url string) func(ctx context.Context) (*http.Response, error) {
+		return func(ctx context.Context) (*http.Response, error) {
+			httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(rawreq))
+			if err != nil {
+				return nil, errors.Wrap(err, "fail to create request")
+			}
+			httpReq.Header.Set("Content-Type", "application/json")
+			upstreamauth.Apply(httpReq.Header, p.upstreamAuth(url))
+			start := time.Now()
+			res, err := p.httpClient.Do(httpReq)
+			p.recordCall(url, res, err, time.Since(start))
+			if err != nil {
+				logger.Error("The rawreq is", zap.ByteString("rawreq", rawreq))
+				return nil, errors.Wrap(upstreamauth.RedactError(err), "fail to post request")
+			}
+			p.latency.Observe(time.Since(start))
+
+			return res, nil
+		}
+	}
+
+	hedgeRPC := ""
+	if requestRPC == p.cfg.HttpUpstream && !req.HasWriteCall(nil) {
+		hedgeRPC = p.hedgeUpstream()
+	}
+	// an open breaker only turns calls away when a hedge upstream can take them, the only upstream is still tried
+	if hedgeRPC != "" && !p.breakers[requestRPC].Ready() {
+		requestRPC, hedgeRPC = hedgeRPC, ""
+	}
+	if hedgeRPC == "" {
+		return post(requestRPC)(ctx)
+	}
+	return hedge.Do(ctx, p.latency.Delay(), post(requestRPC), post(hedgeRPC), func(res *http.Response) { res.Body.Close() })
+}
+
+// upstreamAuth returns the credentials of an http upstream, the private relay and hedge mirrors have none
+func (p *JsonRpcProxy) upstreamAuth(url string) config.UpstreamAuth {
+	if url == p.cfg.HttpUpstream {
+		return p.cfg.Auth
 	}
+	for _, pool := range p.cfg.Pools {
+		if url == pool.Http {
+			return pool.Auth
+		}
+	}
+	return config.UpstreamAuth{}
+}
 
-	res, err := p.httpClient.Post(requestRPC, "application/json", strings.NewReader(string(rawreq)))
+// Call sends a call to the upstream without cache and returns its result, an upstream error is returned as error
+func (p *JsonRpcProxy) Call(ctx context.Context, logger *zap.Logger, method string, params ...interface{}) (json.RawMessage, error) {
+	call, err := newCall(method, params)
 	if err != nil {
-		logger.Error("The rawreq is", zap.ByteString("rawreq", rawreq))
-		return nil, errors.Wrap(err, "fail to post request")
+		return nil, err
 	}
+	return p.callPool(ctx, logger, p.routeCall(call), call)
+}
 
-	buff := bytes.Buffer{}
-	_, err = buff.ReadFrom(res.Body)
+// BlockNumberOf returns the block number of the upstream a call of method with params is sent to, e.g. of the
+// node answering eth_getLogs when it is routed to another pool than the head is tracked on
+func (p *JsonRpcProxy) BlockNumberOf(ctx context.Context, logger *zap.Logger, method string, params ...interface{}) (uint64, error) {
+	call, err := newCall(method, params)
 	if err != nil {
-		return nil, errors.Wrap(err, "fail to read response body")
+		return 0, err
 	}
+	blockNumber, _ := newCall("eth_blockNumber", []interface{}{})
+	result, err := p.callPool(ctx, logger, p.routeCall(call), blockNumber)
+	if err != nil {
+		return 0, err
+	}
+	var number string
+	if err = json.Unmarshal(result, &number); err != nil {
+		return 0, err
+	}
+	return utils.ToUint64(number)
+}
 
-	return buff.Bytes(), nil
+func newCall(method string, params []interface{}) (*jsonrpc.JsonRpcSingleRequest, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	var id interface{} = 1
+	return &jsonrpc.JsonRpcSingleRequest{ID: &id, JsonRpcVersion: "2.0", Method: method, Params: rawParams}, nil
+}
+
+// callPool sends call to the upstream of pool and returns its result, an upstream error is returned as error
+func (p *JsonRpcProxy) callPool(ctx context.Context, logger *zap.Logger, pool string, call *jsonrpc.JsonRpcSingleRequest) (json.RawMessage, error) {
+	resp, err := p.doPoolUpstreamCall(ctx, jsonrpc.NewSingleRequest(call), pool, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamResp := UpstreamJsonRpcResponse{}
+	if err = json.Unmarshal(resp, &upstreamResp); err != nil {
+		return nil, err
+	}
+	if len(upstreamResp.Error) > 0 {
+		return nil, fmt.Errorf("%s: %s", call.Method, upstreamResp.Error)
+	}
+	return upstreamResp.Result, nil
 }
 
 func (p *JsonRpcProxy) HttpUpstream(req *request) ([]byte, error) {
-	resp, err := p.DoHttpUpstreamCall(req.JsonRpcRequest, req.logger)
+	resp, err := p.DoHttpUpstreamCall(req.ctx, req.JsonRpcRequest, req.logger)
 	if err != nil {
 		return nil, err
 	}
@@ -194,37 +609,38 @@ func (p *JsonRpcProxy) HttpUpstream(req *request) ([]byte, error) {
 	}
 
 	// step3. Cache if it is a valid result and cacheable
-	if req.cacheKey != nil && upstreamResp.Result != nil {
-		if err = p.CacheFn(req, upstreamResp.Result); err != nil {
-			req.logger.Error("failed to cache result", zap.Error(err))
-		}
+	if len(resp) <= p.cfg.MaxCacheBytes {
+		p.cacheResult(req, upstreamResp.Result)
 	}
 
 	return resp, nil
 }
 
+// cacheResult caches the result of a cacheable call when it holds a value
+func (p *JsonRpcProxy) cacheResult(req *request, result json.RawMessage) {
+	if req.cacheKey == nil || !isCacheableResult(result) {
+		return
+	}
+	if err := p.CacheFn(req, result); err != nil {
+		req.logger.Error("failed to cache result", zap.Error(err))
+	}
+}
+
 func (p *JsonRpcProxy) NewUpstreamWS(client *Client, logger *zap.Logger) (*UpstreamWebSocket, error) {
-	upstream, _, err := websocket.DefaultDialer.Dial(p.cfg.WsUpstream, nil)
+	upstream, err := utils.DialWs(p.cfg.WsDialer, p.cfg.WsUpstream, upstreamauth.Header(p.cfg.Auth))
 	if err != nil {
 		return nil, err
 	}
 
-	var erigonUpstream *websocket.Conn
-	if p.cfg.WsErigonUpstream != "" {
-		erigonUpstream, _, err = websocket.DefaultDialer.Dial(p.cfg.WsErigonUpstream, nil)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	u := &UpstreamWebSocket{
-		conn:       upstream,
-		erigonConn: erigonUpstream,
-		client:     client,
-		logger:     logger,
-		proxy:      p,
-		mutex:      new(sync.Mutex),
-		requests:   make(map[interface{}]*request),
+		conn:      upstream,
+		poolConns: make(map[string]*websocket.Conn),
+		client:    client,
+		logger:    logger,
+		proxy:     p,
+		mutex:     new(sync.Mutex),
+		requests:  make(map[string]*request),
+		batches:   make(map[string]jsonrpc.JsonRpcSingleRequest),
 	}
 	go u.run()
 	return u, nil
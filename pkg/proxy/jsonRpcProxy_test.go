@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"go.uber.org/zap"
+)
+
+type testHead struct {
+	latest uint64
+	hashes map[uint64]string
+}
+
+func (h testHead) Latest() uint64                    { return h.latest }
+func (h testHead) Safe() uint64                      { return 0 }
+func (h testHead) Finalized() uint64                 { return 0 }
+func (h testHead) Hash(number uint64) (string, bool) { hash, ok := h.hashes[number]; return hash, ok }
+func (h testHead) OnReorg(fn func(number uint64))    {}
+
+func TestRouteCall(t *testing.T) {
+	p := NewJsonRpcProxy(&app.App{}, JsonRpcProxyConfig{
+		HeadTracker: testHead{latest: 1000, hashes: map[uint64]string{1000: "0xhead", 900: "0xold"}},
+		Routes: []config.UpstreamRoute{
+			{Methods: []string{"trace_*"}, Pool: "trace"},
+			{Methods: []string{"eth_getBalance", "eth_call"}, Pool: "archive", MinBlockDepth: 128},
+		},
+	})
+	tests := []struct {
+		method string
+		params string
+		pool   string
+	}{
+		{"trace_block", `["0x1"]`, "trace"},
+		{"eth_blockNumber", `[]`, ""},
+		{"eth_getBalance", `["0x01","latest"]`, ""},
+		{"eth_getBalance", `["0x01","0x3e8"]`, ""},
+		{"eth_getBalance", `["0x01","0x64"]`, "archive"},
+		{"eth_getBalance", `["0x01","earliest"]`, "archive"},
+		{"eth_getBalance", `["0x01",{"blockHash":"0xHEAD"}]`, ""},
+		{"eth_getBalance", `["0x01",{"blockHash":"0xunknown"}]`, "archive"},
+		{"eth_call", `[{},"0x3e7"]`, ""},
+	}
+	for _, test := range tests {
+		call := &jsonrpc.JsonRpcSingleRequest{Method: test.method, Params: json.RawMessage(test.params)}
+		if pool := p.routeCall(call); pool != test.pool {
+			t.Errorf("%s %s: expected pool %q, got %q", test.method, test.params, test.pool, pool)
+		}
+	}
+}
+
+// batchUpstream answers each call of a batch with the name of the upstream
+func batchUpstream(t *testing.T, name string, methods *[]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var calls []jsonrpc.JsonRpcSingleRequest
+		if err := json.Unmarshal(body, &calls); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resps := make([]string, 0, len(calls))
+		// answered in reverse, the proxy restores the order of the client batch
+		for i := len(calls) - 1; i >= 0; i-- {
+			*methods = append(*methods, calls[i].Method)
+			id, _ := json.Marshal(calls[i].ID)
+			resps = append(resps, fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%q}`, id, name))
+		}
+		_, _ = w.Write([]byte("[" + strings.Join(resps, ",") + "]"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMixedPoolBatch(t *testing.T) {
+	var upstreamMethods, traceMethods []string
+	upstream := batchUpstream(t, "upstream", &upstreamMethods)
+	trace := batchUpstream(t, "trace", &traceMethods)
+	p := NewJsonRpcProxy(&app.App{}, JsonRpcProxyConfig{
+		HttpUpstream: upstream.URL,
+		HttpClient:   http.DefaultClient,
+		Pools:        map[string]config.UpstreamPool{"trace": {Http: trace.URL}},
+		Routes:       []config.UpstreamRoute{{Methods: []string{"trace_*"}, Pool: "trace"}},
+	})
+
+	req := jsonrpc.JsonRpcRequest{}
+	body := `[{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber"},{"jsonrpc":"2.0","id":"a","method":"trace_block","params":["0x1"]},{"jsonrpc":"2.0","id":3,"method":"eth_chainId"}]`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	if p.isPlainBatch(&req) {
+		t.Fatal("a batch spanning two pools is not plain")
+	}
+	resp, err := p.batchHttpProxy(context.Background(), zap.NewNop(), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"id":1,"jsonrpc":"2.0","result":"upstream"},{"id":"a","jsonrpc":"2.0","result":"trace"},{"id":3,"jsonrpc":"2.0","result":"upstream"}]`
+	var got, want []map[string]interface{}
+	_ = json.Unmarshal(resp, &got)
+	_ = json.Unmarshal([]byte(expected), &want)
+	gotJson, _ := json.Marshal(got)
+	wantJson, _ := json.Marshal(want)
+	if string(gotJson) != string(wantJson) {
+		t.Errorf("expected %s, got %s", expected, resp)
+	}
+	if len(upstreamMethods) != 2 || len(traceMethods) != 1 || traceMethods[0] != "trace_block" {
+		t.Errorf("unexpected split, upstream got %v and trace got %v", upstreamMethods, traceMethods)
+	}
+}
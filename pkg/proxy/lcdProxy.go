@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/compression"
+	"starnet/chain-api/pkg/upstreamauth"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// LcdHeightHeader pins a cosmos sdk rest query to a block height, the same as the height query param
+const LcdHeightHeader = "x-cosmos-block-height"
+
+type LcdProxyConfig struct {
+	Upstream   string
+	Auth       config.UpstreamAuth
+	HttpClient *http.Client
+	ChainID    uint8
+
+	// CacheTime is how long responses of height pinned queries are cached, a zero duration disables caching
+	CacheTime time.Duration
+}
+
+type LcdRequest struct {
+	Method   string
+	Path     string // starts with /, e.g. /cosmos/bank/v1beta1/balances/{address}
+	RawQuery string
+	Header   http.Header
+	Body     []byte
+}
+
+type LcdResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// LcdProxy forwards cosmos sdk rest (lcd / grpc-gateway) requests to a single upstream
+type LcdProxy struct {
+	rdb        redis.UniversalClient
+	httpClient *http.Client
+	cfg        *LcdProxyConfig
+}
+
+func NewLcdProxy(app *app.App, cfg LcdProxyConfig) *LcdProxy {
+	return &LcdProxy{
+		rdb:        app.Rdb,
+		httpClient: cfg.HttpClient,
+		cfg:        &cfg,
+	}
+}
+
+// Height returns the block height a query is pinned to, 0 for the latest state
+func (r *LcdRequest) Height() int64 {
+	height := r.Header.Get(LcdHeightHeader)
+	if height == "" {
+		if values, err := url.ParseQuery(r.RawQuery); err == nil {
+			height = values.Get("height")
+		}
+	}
+	h, err := strconv.ParseInt(height, 10, 64)
+	if err != nil || h < 0 {
+		return 0
+	}
+	return h
+}
+
+func (p *LcdProxy) cacheKey(req *LcdRequest) string {
+	hash := md5.Sum([]byte(req.Path + "?" + req.RawQuery + "@" + strconv.FormatInt(req.Height(), 10)))
+	return fmt.Sprintf("lcd:%d:%s", p.cfg.ChainID, hex.EncodeToString(hash[:]))
+}
+
+func (p *LcdProxy) Proxy(ctx context.Context, logger *zap.Logger, req *LcdRequest) (*LcdResponse, error) {
+	// only queries at a fixed height are immutable, latest state changes every block
+	cacheable := p.cfg.CacheTime > 0 && req.Method == http.MethodGet && req.Height() > 0
+	var cacheKey string
+	if cacheable {
+		cacheKey = p.cacheKey(req)
+		res, err := p.rdb.Get(ctx, cacheKey).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if res, err = compression.Unpack(res); err != nil {
+			return nil, err
+		}
+		if len(res) > 0 {
+			logger.Debug("got lcd resp from cache", zap.String("path", req.Path))
+			return &LcdResponse{StatusCode: http.StatusOK, ContentType: "application/json", Body: res}, nil
+		}
+	}
+
+	resp, err := p.doUpstreamCall(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable && resp.StatusCode == http.StatusOK {
+		if err := p.rdb.Set(ctx, cacheKey, compression.Pack(resp.Body), p.cfg.CacheTime).Err(); err != nil {
+			logger.Error("failed to cache lcd result", zap.Error(err))
+		}
+	}
+
+	return resp, nil
+}
+
+func (p *LcdProxy) doUpstreamCall(ctx context.Context, req *LcdRequest) (*LcdResponse, error) {
+	upstreamUrl := p.cfg.Upstream + req.Path
+	if req.RawQuery != "" {
+		upstreamUrl += "?" + req.RawQuery
+	}
+	upstreamReq, err := http.NewRequestWithContext(ctx, req.Method, upstreamUrl, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"Content-Type", "Accept", LcdHeightHeader} {
+		if value := req.Header.Get(name); value != "" {
+			upstreamReq.Header.Set(name, value)
+		}
+	}
+	upstreamauth.Apply(upstreamReq.Header, p.cfg.Auth)
+
+	res, err := p.httpClient.Do(upstreamReq)
+	if err != nil {
+		return nil, upstreamauth.RedactError(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LcdResponse{
+		StatusCode:  res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		Body:        body,
+	}, nil
+}
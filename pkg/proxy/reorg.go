@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"go.uber.org/zap"
+)
+
+// reorgTimeout bounds the cache invalidation of a reorg
+const reorgTimeout = time.Second * 10
+
+// blockCacheKey is the set of the cache keys of calls reading a block by number which is not finalized yet, a
+// reorg replacing the block deletes them
+func (p *JsonRpcProxy) blockCacheKey(number uint64) string {
+	return fmt.Sprintf("rpc:%d:block:%d", p.cfg.ChainID, number)
+}
+
+// reorgBlock returns the block number a cacheable call reads when a reorg may replace it, ok is false for calls
+// by tag or hash and for finalized blocks
+func (p *JsonRpcProxy) reorgBlock(call *jsonrpc.JsonRpcSingleRequest) (number uint64, ok bool) {
+	if p.cfg.HeadTracker == nil {
+		return 0, false
+	}
+	block, ok := jsonrpc.EvmBlockParam(call)
+	if !ok || block.Tag != "" || block.Hash != "" {
+		return 0, false
+	}
+	if finalized := p.cfg.HeadTracker.Finalized(); finalized > 0 && block.Number <= finalized {
+		return 0, false
+	}
+	return block.Number, true
+}
+
+// invalidateReorg deletes the cached calls reading a block from number on, they may hold replaced blocks
+func (p *JsonRpcProxy) invalidateReorg(ctx context.Context, number uint64) {
+	ctx, cancel := context.WithTimeout(ctx, reorgTimeout)
+	defer cancel()
+
+	head := p.cfg.HeadTracker.Latest()
+	for n := number; n <= head && n < number+maxHashLookback; n++ {
+		blockKey := p.blockCacheKey(n)
+		keys, err := p.rdb.SMembers(ctx, blockKey).Result()
+		if err != nil {
+			p.logger.Error("failed to read the cache keys of a reorged block", zap.Uint64("block", n), zap.Error(err))
+			return
+		}
+		if err = p.rdb.Del(ctx, append(keys, blockKey)...).Err(); err != nil {
+			p.logger.Error("failed to delete the cache of a reorged block", zap.Uint64("block", n), zap.Error(err))
+			return
+		}
+	}
+}
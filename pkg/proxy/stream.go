@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"go.uber.org/zap"
+)
+
+// HttpProxyStream is HttpProxy returning the response as a stream, the upstream response body is piped through
+// instead of being read first. Only the response of a cacheable call is buffered alongside, up to MaxCacheBytes,
+// to be cached once it has been read. The caller closes the stream.
+func (p *JsonRpcProxy) HttpProxyStream(ctx context.Context, logger *zap.Logger, rawreq *jsonrpc.JsonRpcRequest) (io.ReadCloser, error) {
+	if rawreq.IsBatchCall() {
+		if !p.isPlainBatch(rawreq) {
+			resp, err := p.batchHttpProxy(ctx, logger, rawreq)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(bytes.NewReader(resp)), nil
+		}
+		return p.streamUpstream(ctx, logger, rawreq, nil)
+	}
+
+	req, err := p.fromRequest(rawreq)
+	if err != nil {
+		return nil, err
+	}
+	req.ctx = ctx
+	req.logger = logger
+
+	resp, err := p.fromCache(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil {
+		return io.NopCloser(bytes.NewReader(resp)), nil
+	}
+
+	var done func(resp []byte)
+	if req.cacheKey != nil {
+		done = func(resp []byte) {
+			upstreamResp := UpstreamJsonRpcResponse{}
+			if err := json.Unmarshal(resp, &upstreamResp); err != nil || len(upstreamResp.Error) > 0 {
+				return
+			}
+			p.cacheResult(req, upstreamResp.Result)
+		}
+	}
+	return p.streamUpstream(ctx, logger, rawreq, done)
+}
+
+// streamUpstream posts req upstream and returns the response body, done gets the whole response once it has been
+// read when it fits MaxCacheBytes. A non 200 response is only passed on when it is json.
+func (p *JsonRpcProxy) streamUpstream(ctx context.Context, logger *zap.Logger, req *jsonrpc.JsonRpcRequest, done func(resp []byte)) (io.ReadCloser, error) {
+	res, err := p.doHttpUpstream(ctx, req, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		resp, err := io.ReadAll(io.LimitReader(res.Body, int64(p.cfg.MaxCacheBytes)))
+		if err != nil || !json.Valid(resp) {
+			return nil, fmt.Errorf("upstream status %s", res.Status)
+		}
+		return io.NopCloser(bytes.NewReader(resp)), nil
+	}
+
+	if done == nil {
+		return res.Body, nil
+	}
+	return &cachingBody{ReadCloser: res.Body, limit: p.cfg.MaxCacheBytes, done: done}, nil
+}
+
+// cachingBody passes a response through and keeps a copy of up to limit bytes, done gets the copy at the end of
+// the response unless it was larger
+type cachingBody struct {
+	io.ReadCloser
+	limit    int
+	buff     bytes.Buffer
+	overflow bool
+	done     func(resp []byte)
+}
+
+func (b *cachingBody) Read(data []byte) (int, error) {
+	n, err := b.ReadCloser.Read(data)
+	if !b.overflow {
+		if b.buff.Len()+n > b.limit {
+			b.overflow = true
+			b.buff = bytes.Buffer{}
+		} else {
+			b.buff.Write(data[:n])
+		}
+	}
+	if err == io.EOF && !b.overflow && b.done != nil {
+		b.done(b.buff.Bytes())
+		b.done = nil
+	}
+	return n, err
+}
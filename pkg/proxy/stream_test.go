@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/jsonrpc"
+
+	"go.uber.org/zap"
+)
+
+func TestCachingBody(t *testing.T) {
+	tests := []struct {
+		resp   string
+		limit  int
+		cached bool
+	}{
+		{`{"result":"0x1"}`, 64, true},
+		{`{"result":"` + strings.Repeat("ab", 64) + `"}`, 64, false},
+	}
+	for _, test := range tests {
+		var cached []byte
+		body := &cachingBody{
+			ReadCloser: io.NopCloser(strings.NewReader(test.resp)),
+			limit:      test.limit,
+			done:       func(resp []byte) { cached = append([]byte{}, resp...) },
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.resp {
+			t.Errorf("expected the response passed through, got %.40s", data)
+		}
+		if (cached != nil) != test.cached || (test.cached && !bytes.Equal(cached, data)) {
+			t.Errorf("%.40s with limit %d: expected cached %v, got %.40s", test.resp, test.limit, test.cached, cached)
+		}
+	}
+}
+
+func TestHttpProxyStream(t *testing.T) {
+	tests := []struct {
+		status int
+		resp   string
+		err    bool
+	}{
+		{http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat("ab", 4096) + `"}`, false},
+		{http.StatusBadRequest, `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"invalid"}}`, false},
+		{http.StatusBadGateway, `<html>bad gateway</html>`, true},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			_, _ = w.Write([]byte(test.resp))
+		}))
+		p := NewJsonRpcProxy(&app.App{}, JsonRpcProxyConfig{HttpUpstream: server.URL, HttpClient: server.Client()})
+
+		var id interface{} = 1
+		req := jsonrpc.NewSingleRequest(&jsonrpc.JsonRpcSingleRequest{ID: &id, JsonRpcVersion: "2.0", Method: "debug_traceBlockByNumber"})
+		body, err := p.HttpProxyStream(context.Background(), zap.NewNop(), req)
+		if test.err {
+			if err == nil {
+				t.Errorf("status %d: expected an error for a response which is not json", test.status)
+			}
+			server.Close()
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil || string(data) != test.resp {
+			t.Errorf("status %d: expected the upstream response, got %.40s %v", test.status, data, err)
+		}
+		server.Close()
+	}
+}
@@ -12,9 +12,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"sync/atomic"
+	"time"
 
+	"starnet/chain-api/pkg/compression"
 	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/upstreamauth"
 	"starnet/chain-api/pkg/utils"
 
 	"github.com/go-redis/redis/v8"
@@ -46,19 +50,33 @@ func (p *JsonRpcProxy) TendermintProxy(ctx context.Context, logger *zap.Logger,
 	return p.TendermintUpstream(req)
 }
 
+func (p *JsonRpcProxy) tendermintCacheTime(req *jsonrpc.TenderMintRequest) time.Duration {
+	if p.cfg.TendermintCacheTimeFn != nil {
+		return p.cfg.TendermintCacheTimeFn(req)
+	}
+	return p.cfg.CacheTime
+}
+
 func (p *JsonRpcProxy) fromTendermintCache(req *request) ([]byte, error) {
 	// step1. Try to get result from cache
-	hash := md5.Sum([]byte(req.TenderMintRequest.Path + req.TenderMintRequest.URLQuery))
+	hash := md5.Sum([]byte(req.TenderMintRequest.URLQuery()))
 	cacheKey := fmt.Sprintf("tendermint:%d:%s:%s", p.cfg.ChainID, req.TenderMintRequest.Path, hex.EncodeToString(hash[:]))
-	cacheable := utils.In(req.TenderMintRequest.Path, p.cfg.CacheableMethods)
-	if cacheable {
+	cacheTime := time.Duration(0)
+	if utils.In(req.TenderMintRequest.Path, p.cfg.CacheableMethods) {
+		cacheTime = p.tendermintCacheTime(req.TenderMintRequest)
+	}
+	if cacheTime > 0 {
 		req.cacheKey = &cacheKey
+		req.cacheTime = cacheTime
 		req.cacheFn = p.CacheFn
 
 		res, err := p.rdb.Get(req.ctx, cacheKey).Bytes()
 		if err != nil && !errors.Is(err, redis.Nil) {
 			return nil, err
 		}
+		if res, err = compression.Unpack(res); err != nil {
+			return nil, err
+		}
 
 		// 获取到了内容，则直接返回
 		if len(res) > 0 {
@@ -71,13 +89,21 @@ func (p *JsonRpcProxy) fromTendermintCache(req *request) ([]byte, error) {
 	return nil, nil
 }
 
-func (p *JsonRpcProxy) DoTendermintUpstreamCall(req *jsonrpc.TenderMintRequest) ([]byte, error) {
-	res, err := p.httpClient.Get(p.cfg.HttpUpstream + "/" + req.Path + req.URLQuery)
+func (p *JsonRpcProxy) DoTendermintUpstreamCall(ctx context.Context, req *jsonrpc.TenderMintRequest) ([]byte, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.HttpUpstream+"/"+req.Path+req.URLQuery(), nil)
 	if err != nil {
 		return nil, err
 	}
+	upstreamauth.Apply(httpReq.Header, p.cfg.Auth)
+	start := time.Now()
+	res, err := p.httpClient.Do(httpReq)
+	p.recordCall(p.cfg.HttpUpstream, res, err, time.Since(start))
+	if err != nil {
+		return nil, upstreamauth.RedactError(err)
+	}
+	defer res.Body.Close()
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf(res.Status)
+		return nil, errors.New(res.Status)
 	}
 
 	buff := bytes.Buffer{}
@@ -90,7 +116,7 @@ func (p *JsonRpcProxy) DoTendermintUpstreamCall(req *jsonrpc.TenderMintRequest)
 }
 
 func (p *JsonRpcProxy) TendermintUpstream(req *request) ([]byte, error) {
-	resp, err := p.DoTendermintUpstreamCall(req.TenderMintRequest)
+	resp, err := p.DoTendermintUpstreamCall(req.ctx, req.TenderMintRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +129,7 @@ func (p *JsonRpcProxy) TendermintUpstream(req *request) ([]byte, error) {
 	}
 
 	// step3. Cache if it is a valid result and cacheable
-	if req.cacheKey != nil && upstreamResp.Result != nil {
+	if req.cacheKey != nil && len(upstreamResp.Error) == 0 && isCacheableResult(upstreamResp.Result) {
 		saveDate, err := json.Marshal(upstreamResp)
 		if err != nil {
 			return resp, nil
@@ -8,10 +8,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"sync"
 	"time"
 
 	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/upstreamauth"
+	"starnet/chain-api/pkg/utils"
 
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
@@ -52,32 +55,108 @@ func (c *Client) Send(data RespData) {
 }
 
 type UpstreamWebSocket struct {
-	conn       *websocket.Conn
-	erigonConn *websocket.Conn
-	client     *Client
-	proxy      *JsonRpcProxy
-	logger     *zap.Logger
+	conn      *websocket.Conn
+	poolConns map[string]*websocket.Conn // routed pools with a ws endpoint, dialed on first use
+	client    *Client
+	proxy     *JsonRpcProxy
+	logger    *zap.Logger
 
 	mutex    *sync.Mutex
-	requests map[interface{}]*request
+	closed   bool
+	requests map[string]*request                     // keyed by the json encoded id of the client call
+	batches  map[string]jsonrpc.JsonRpcSingleRequest // calls of the batches sent upstream, keyed like requests
+}
+
+// requestKey encodes a call or response id, so the number 1 of a call and of its response share the key
+func requestKey(id interface{}) string {
+	key, _ := json.Marshal(id)
+	return string(key)
 }
 
 func (u *UpstreamWebSocket) Close() error {
 	u.client.SetClosed()
-	if err := u.conn.Close(); err != nil {
-		return err
+	err := u.conn.Close()
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.closed = true
+	for _, conn := range u.poolConns {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
 	}
-	if u.erigonConn != nil {
-		if err := u.erigonConn.Close(); err != nil {
-			return err
+	return err
+}
+
+// routedPool returns the pool the call is routed to, "" for the chain upstream and pools without a ws endpoint
+func (u *UpstreamWebSocket) routedPool(rawreq *jsonrpc.JsonRpcRequest) string {
+	name := u.proxy.route(rawreq)
+	if pool, ok := u.proxy.cfg.Pools[name]; ok && pool.Ws != "" {
+		return name
+	}
+	return ""
+}
+
+// upstreamConn returns the connection of the pool the call is routed to, a pool is dialed with its first call.
+// The session ends when a pool connection fails, the calls sent to it would never be answered.
+func (u *UpstreamWebSocket) upstreamConn(rawreq *jsonrpc.JsonRpcRequest) (*websocket.Conn, error) {
+	name := u.routedPool(rawreq)
+	if name == "" {
+		return u.conn, nil
+	}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if conn, ok := u.poolConns[name]; ok {
+		return conn, nil
+	}
+	if u.closed {
+		return nil, errors.New("upstream websocket closed")
+	}
+	pool := u.proxy.cfg.Pools[name]
+	conn, err := utils.DialWs(u.proxy.cfg.WsDialer, pool.Ws, upstreamauth.Header(pool.Auth))
+	if err != nil {
+		return nil, err
+	}
+	u.poolConns[name] = conn
+	go func() {
+		u.readUpstream(conn)
+		// ends run, which closes the client connection
+		_ = u.conn.Close()
+	}()
+	return conn, nil
+}
+
+// singleConn reports whether all calls of a batch are routed to the same upstream connection
+func (u *UpstreamWebSocket) singleConn(rawreq *jsonrpc.JsonRpcRequest) bool {
+	calls := rawreq.GetBatchCall()
+	for i := range calls {
+		if u.routedPool(jsonrpc.NewSingleRequest(&calls[i])) != u.routedPool(jsonrpc.NewSingleRequest(&calls[0])) {
+			return false
 		}
 	}
-	return nil
+	return true
 }
 
 func (u *UpstreamWebSocket) Send(ctx context.Context, logger *zap.Logger, rawreq *jsonrpc.JsonRpcRequest) error {
 	if rawreq.IsBatchCall() {
-		return u.conn.WriteJSON(rawreq)
+		if !u.singleConn(rawreq) {
+			// the http path splits the batch per pool and reassembles the responses
+			resp, err := u.proxy.batchHttpProxy(ctx, logger, rawreq)
+			if err != nil {
+				return err
+			}
+			u.client.Send(RespData{Data: resp, BatchCalls: rawreq.GetBatchCall()})
+			return nil
+		}
+		conn, err := u.upstreamConn(rawreq)
+		if err != nil {
+			return err
+		}
+		u.mutex.Lock()
+		for _, call := range rawreq.GetBatchCall() {
+			u.batches[requestKey(call.ID)] = call
+		}
+		u.mutex.Unlock()
+		return conn.WriteJSON(rawreq)
 	}
 	p := u.proxy
 	req, err := p.fromRequest(rawreq)
@@ -93,24 +172,31 @@ func (u *UpstreamWebSocket) Send(ctx context.Context, logger *zap.Logger, rawreq
 		return err
 	}
 	if resp != nil {
-		u.client.Send(RespData{Data: resp})
+		u.client.Send(RespData{Data: resp, RequestMethod: rawreq.GetSingleCall().Method})
+		return nil
+	}
+
+	if rawreq.RequestType == jsonrpc.RequestTypePrivate {
+		// the relay only speaks http
+		if resp, err = p.HttpUpstream(req); err != nil {
+			return err
+		}
+		u.client.Send(RespData{Data: resp, RequestMethod: rawreq.GetSingleCall().Method})
 		return nil
 	}
 
+	conn, err := u.upstreamConn(rawreq)
+	if err != nil {
+		return err
+	}
 	u.mutex.Lock()
-	u.requests[req.ID] = req
+	u.requests[requestKey(rawreq.GetSingleCall().ID)] = req
 	u.mutex.Unlock()
-	if rawreq.RequestType == jsonrpc.RequestTypeErigon {
-		return u.erigonConn.WriteJSON(req)
-	}
-	return u.conn.WriteJSON(req)
+	return conn.WriteJSON(req)
 }
 
 func (u *UpstreamWebSocket) run() {
-	defer u.conn.Close()
-	if u.erigonConn != nil {
-		defer u.erigonConn.Close()
-	}
+	defer u.Close()
 	defer u.client.conn.Close()
 
 	u.conn.SetPongHandler(func(appData string) error {
@@ -121,64 +207,33 @@ func (u *UpstreamWebSocket) run() {
 		return u.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
 	})
 
-	p := u.proxy
-
-	if u.erigonConn != nil {
-		go func() {
-			erigonWs := u.erigonConn
-			for {
-				if erigonWs == nil {
-					break
-				}
-				_, rawresp, err := erigonWs.ReadMessage()
-				if err != nil {
-					return
-				}
-				u.logger.Debug("got resp from upstream", zap.ByteString("rawresp", rawresp))
-
-				rawresp = bytes.TrimSpace(rawresp)
-				if rawresp[0] == '[' && rawresp[len(rawresp)-1] == ']' {
-					// batch call response
-					u.client.Send(RespData{Data: rawresp})
-					continue
-				}
-
-				// req.logger.Debug("new upstream response", zap.ByteString("resp", rawreq))
-				upstreamResp := UpstreamJsonRpcResponse{}
-				if err = json.Unmarshal(rawresp, &upstreamResp); err != nil {
-					return
-				}
-
-				// 订阅的通知是没有 id 字段的
-				if upstreamResp.ID == 0 {
-					// 直接把内容写入到客户端
-					u.client.Send(RespData{Data: rawresp, Subscription: true})
-					continue
-				}
-
-				u.mutex.Lock()
-				req, ok := u.requests[upstreamResp.ID]
-				u.mutex.Unlock()
-				if ok {
-
-					u.mutex.Lock()
-					delete(u.requests, upstreamResp.ID)
-					u.mutex.Unlock()
-
-					// step3. Cache if it is a valid result and cacheable
-					if req.cacheKey != nil && upstreamResp.Result != nil {
-						if err = p.CacheFn(req, upstreamResp.Result); err != nil {
-							req.logger.Error("failed to cache result", zap.Error(err))
-						}
-					}
-				}
+	u.readUpstream(u.conn)
+}
 
-				u.client.Send(RespData{Data: rawresp})
-			}
-		}()
+// batchCalls returns the calls the responses of a batch answer and forgets them
+func (u *UpstreamWebSocket) batchCalls(rawresp []byte) []jsonrpc.JsonRpcSingleRequest {
+	var items []struct {
+		ID interface{} `json:"id"`
 	}
+	if err := json.Unmarshal(rawresp, &items); err != nil {
+		return nil
+	}
+	calls := make([]jsonrpc.JsonRpcSingleRequest, 0, len(items))
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for _, item := range items {
+		key := requestKey(item.ID)
+		if call, ok := u.batches[key]; ok {
+			calls = append(calls, call)
+			delete(u.batches, key)
+		}
+	}
+	return calls
+}
 
-	ws := u.conn
+// readUpstream forwards upstream messages to the client until the upstream connection fails
+func (u *UpstreamWebSocket) readUpstream(ws *websocket.Conn) {
+	p := u.proxy
 	for {
 		_, rawresp, err := ws.ReadMessage()
 		if err != nil {
@@ -189,7 +244,7 @@ func (u *UpstreamWebSocket) run() {
 		rawresp = bytes.TrimSpace(rawresp)
 		if rawresp[0] == '[' && rawresp[len(rawresp)-1] == ']' {
 			// batch call response
-			u.client.Send(RespData{Data: rawresp})
+			u.client.Send(RespData{Data: rawresp, BatchCalls: u.batchCalls(rawresp)})
 			continue
 		}
 
@@ -200,29 +255,29 @@ func (u *UpstreamWebSocket) run() {
 		}
 
 		// 订阅的通知是没有 id 字段的
-		if upstreamResp.ID == 0 {
+		if upstreamResp.ID == nil {
 			// 直接把内容写入到客户端
 			u.client.Send(RespData{Data: rawresp, Subscription: true})
 			continue
 		}
 
+		resp := RespData{Data: rawresp}
+		key := requestKey(upstreamResp.ID)
 		u.mutex.Lock()
-		req, ok := u.requests[upstreamResp.ID]
+		req, ok := u.requests[key]
+		delete(u.requests, key)
 		u.mutex.Unlock()
 		if ok {
-
-			u.mutex.Lock()
-			delete(u.requests, upstreamResp.ID)
-			u.mutex.Unlock()
+			resp.RequestMethod = req.GetSingleCall().Method
 
 			// step3. Cache if it is a valid result and cacheable
-			if req.cacheKey != nil && upstreamResp.Result != nil {
+			if req.cacheKey != nil && isCacheableResult(upstreamResp.Result) {
 				if err = p.CacheFn(req, upstreamResp.Result); err != nil {
 					req.logger.Error("failed to cache result", zap.Error(err))
 				}
 			}
 		}
 
-		u.client.Send(RespData{Data: rawresp})
+		u.client.Send(resp)
 	}
 }
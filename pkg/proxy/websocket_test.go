@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/sanitize"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// wsServer answers every message with resp, after the first one it closes the connection when once is set
+func wsServer(t *testing.T, resp string, once bool) string {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(resp)); err != nil || once {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// wsUpstream is a connection to a wsServer
+func wsUpstream(t *testing.T, resp string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(wsServer(t, resp, false), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newTestUpstreamWS(conn *websocket.Conn, send chan RespData, cfg JsonRpcProxyConfig) *UpstreamWebSocket {
+	return &UpstreamWebSocket{
+		conn:      conn,
+		poolConns: map[string]*websocket.Conn{},
+		client:    NewClient(nil, send),
+		proxy:     NewJsonRpcProxy(&app.App{}, cfg),
+		logger:    zap.NewNop(),
+		mutex:     new(sync.Mutex),
+		requests:  map[string]*request{},
+		batches:   map[string]jsonrpc.JsonRpcSingleRequest{},
+	}
+}
+
+func TestWsPoolDialedOnUse(t *testing.T) {
+	conn := wsUpstream(t, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`)
+	send := make(chan RespData, 4)
+	u := newTestUpstreamWS(conn, send, JsonRpcProxyConfig{
+		Pools:  map[string]config.UpstreamPool{"trace": {Ws: wsServer(t, `{"jsonrpc":"2.0","id":2,"result":{}}`, true)}},
+		Routes: []config.UpstreamRoute{{Methods: []string{"trace_*"}, Pool: "trace"}},
+	})
+	ended := make(chan struct{})
+	go func() {
+		u.readUpstream(conn)
+		close(ended)
+	}()
+
+	var id1, id2 interface{} = 1, 2
+	if err := u.Send(context.Background(), zap.NewNop(), jsonrpc.NewSingleRequest(&jsonrpc.JsonRpcSingleRequest{ID: &id1, JsonRpcVersion: "2.0", Method: "eth_blockNumber"})); err != nil {
+		t.Fatal(err)
+	}
+	<-send
+	if len(u.poolConns) != 0 {
+		t.Fatal("expected the pool to be dialed with its first call only")
+	}
+	if err := u.Send(context.Background(), zap.NewNop(), jsonrpc.NewSingleRequest(&jsonrpc.JsonRpcSingleRequest{ID: &id2, JsonRpcVersion: "2.0", Method: "trace_block"})); err != nil {
+		t.Fatal(err)
+	}
+	if resp := <-send; resp.RequestMethod != "trace_block" {
+		t.Errorf("expected the trace_block response, got %s", resp.Data)
+	}
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected the session to end with its pool connection")
+	}
+}
+
+func TestWsBatchSanitized(t *testing.T) {
+	conn := wsUpstream(t, `[{"id":"a","result":[{"network":{"remoteAddress":"1.1.1.1:30303"}}]},{"id":1,"result":"0x1"}]`)
+	send := make(chan RespData, 1)
+	u := newTestUpstreamWS(conn, send, JsonRpcProxyConfig{})
+	go u.readUpstream(conn)
+
+	req := jsonrpc.JsonRpcRequest{}
+	if err := json.Unmarshal([]byte(`[{"id":1,"method":"eth_blockNumber"},{"id":"a","method":"admin_peers"}]`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Send(context.Background(), zap.NewNop(), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp RespData
+	select {
+	case resp = <-send:
+	case <-time.After(time.Second * 5):
+		t.Fatal("no batch response")
+	}
+	if len(resp.BatchCalls) != 2 || len(u.batches) != 0 {
+		t.Fatalf("expected the 2 calls of the batch, got %+v with %d left", resp.BatchCalls, len(u.batches))
+	}
+
+	p, err := sanitize.New([]config.SanitizeRule{{Methods: []string{"admin_peers"}, Redact: []string{"/result/*/network/remoteAddress"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sanitized, err := p.ApplyBatch(resp.BatchCalls, resp.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sanitized), "1.1.1.1") {
+		t.Errorf("batch response over ws not sanitized: %s", sanitized)
+	}
+}
@@ -0,0 +1,188 @@
+package sanitize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/utils"
+
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+)
+
+type rule struct {
+	methods []string
+	redact  [][]string
+	query   *gojq.Query
+}
+
+// Pipeline removes node internals (ips, peers, versions) from rpc responses, a nil Pipeline changes nothing
+type Pipeline struct {
+	rules []rule
+}
+
+func New(rules []config.SanitizeRule) (*Pipeline, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	p := &Pipeline{}
+	for _, r := range rules {
+		compiled := rule{methods: r.Methods}
+		for _, pointer := range r.Redact {
+			path, err := parsePointer(pointer)
+			if err != nil {
+				return nil, err
+			}
+			compiled.redact = append(compiled.redact, path)
+		}
+		if r.Jq != "" {
+			query, err := gojq.Parse(r.Jq)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse sanitize expression %s", r.Jq)
+			}
+			compiled.query = query
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+// parsePointer splits a json pointer (RFC 6901) into its unescaped segments
+func parsePointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+	path := strings.Split(pointer[1:], "/")
+	for i, segment := range path {
+		path[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+	}
+	return path, nil
+}
+
+// Matches reports whether responses of method are rewritten
+func (p *Pipeline) Matches(method string) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.rules {
+		if utils.In(method, r.methods) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply runs the rules of method on a single response
+func (p *Pipeline) Apply(method string, resp []byte) ([]byte, error) {
+	if !p.Matches(method) {
+		return resp, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(resp))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal response")
+	}
+
+	for _, r := range p.rules {
+		if !utils.In(method, r.methods) {
+			continue
+		}
+		for _, path := range r.redact {
+			redact(data, path)
+		}
+		if r.query != nil {
+			v, ok := r.query.Run(data).Next()
+			if !ok {
+				return nil, fmt.Errorf("sanitize expression of %s has no output", method)
+			}
+			if err, ok := v.(error); ok {
+				return nil, err
+			}
+			data = v
+		}
+	}
+
+	buff := bytes.Buffer{}
+	encoder := json.NewEncoder(&buff)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buff.Bytes(), "\n"), nil
+}
+
+// ApplyBatch runs the rules on every response of a batch, responses are matched to calls by id
+func (p *Pipeline) ApplyBatch(calls []jsonrpc.JsonRpcSingleRequest, resp []byte) ([]byte, error) {
+	methods := map[string]string{}
+	for _, call := range calls {
+		if p.Matches(call.Method) {
+			id, _ := json.Marshal(call.ID)
+			methods[string(id)] = call.Method
+		}
+	}
+	if len(methods) == 0 {
+		return resp, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(resp, &items); err != nil {
+		// not a batch response, e.g. an error object for the whole batch
+		return resp, nil
+	}
+	for i, item := range items {
+		var header struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(item, &header); err != nil {
+			continue
+		}
+		method, ok := methods[string(header.ID)]
+		if !ok {
+			continue
+		}
+		sanitized, err := p.Apply(method, item)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = sanitized
+	}
+	return json.Marshal(items)
+}
+
+func redact(v interface{}, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	segment, last := path[0], len(path) == 1
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			if segment != "*" && key != segment {
+				continue
+			}
+			if last {
+				node[key] = ""
+			} else {
+				redact(child, path[1:])
+			}
+		}
+	case []interface{}:
+		for i, child := range node {
+			if segment != "*" && strconv.Itoa(i) != segment {
+				continue
+			}
+			if last {
+				node[i] = ""
+			} else {
+				redact(child, path[1:])
+			}
+		}
+	}
+}
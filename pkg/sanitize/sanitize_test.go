@@ -0,0 +1,100 @@
+package sanitize
+
+import (
+	"encoding/json"
+	"testing"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/jsonrpc"
+)
+
+func TestApply(t *testing.T) {
+	p, err := New([]config.SanitizeRule{
+		{
+			Methods: []string{"status"},
+			Redact:  []string{"/result/node_info/other/rpc_address"},
+		},
+		{
+			Methods: []string{"net_info"},
+			Redact:  []string{"/result/peers/*/remote_ip", "/result/listeners"},
+		},
+		{
+			Methods: []string{"web3_clientVersion"},
+			Jq:      `if (.result | type) == "string" then .result |= (split("/") | .[0]) else . end`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method   string
+		resp     string
+		expected string
+	}{
+		{
+			"status",
+			`{"jsonrpc":"2.0","id":-1,"result":{"node_info":{"other":{"tx_index":"on","rpc_address":"tcp://10.0.0.1:26657"}},"sync_info":{"latest_block_height":"123456789012345678"}}}`,
+			`{"id":-1,"jsonrpc":"2.0","result":{"node_info":{"other":{"rpc_address":"","tx_index":"on"}},"sync_info":{"latest_block_height":"123456789012345678"}}}`,
+		},
+		{
+			"net_info",
+			`{"id":1,"result":{"listeners":["Listener(@1.2.3.4:26656)"],"n_peers":"2","peers":[{"remote_ip":"1.1.1.1"},{"remote_ip":"2.2.2.2"}]}}`,
+			`{"id":1,"result":{"listeners":"","n_peers":"2","peers":[{"remote_ip":""},{"remote_ip":""}]}}`,
+		},
+		{
+			"web3_clientVersion",
+			`{"jsonrpc":"2.0","id":12345678901234567890,"result":"Geth/v1.10.18-stable/linux-amd64/go1.18"}`,
+			`{"id":12345678901234567890,"jsonrpc":"2.0","result":"Geth"}`,
+		},
+		{
+			"web3_clientVersion",
+			`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"<nope>"}}`,
+			`{"error":{"code":-32601,"message":"<nope>"},"id":1,"jsonrpc":"2.0"}`,
+		},
+		{
+			"eth_blockNumber",
+			`{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+			`{"jsonrpc":"2.0","id":1,"result":"0x1"}`,
+		},
+	}
+	for _, test := range tests {
+		resp, err := p.Apply(test.method, []byte(test.resp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(resp) != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.method, test.expected, resp)
+		}
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	p, err := New([]config.SanitizeRule{{Methods: []string{"admin_peers"}, Redact: []string{"/result/*/network/remoteAddress"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []jsonrpc.JsonRpcSingleRequest
+	if err := json.Unmarshal([]byte(`[{"id":1,"method":"eth_blockNumber"},{"id":"a","method":"admin_peers"}]`), &calls); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.ApplyBatch(calls, []byte(`[{"id":"a","result":[{"network":{"remoteAddress":"1.1.1.1:30303"}}]},{"id":1,"result":"0x1"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"id":"a","result":[{"network":{"remoteAddress":""}}]},{"id":1,"result":"0x1"}]`
+	if string(resp) != expected {
+		t.Errorf("expected %s, got %s", expected, resp)
+	}
+}
+
+func TestNilPipeline(t *testing.T) {
+	p, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Apply("status", []byte(`{"result":{}}`))
+	if err != nil || string(resp) != `{"result":{}}` {
+		t.Errorf("unexpected response %s %v", resp, err)
+	}
+}
@@ -0,0 +1,268 @@
+package subscription
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"starnet/chain-api/config"
+	"starnet/chain-api/pkg/jsonrpc"
+	"starnet/chain-api/pkg/upstreamauth"
+	"starnet/chain-api/pkg/utils"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// Subscription kinds served by the Hub, other kinds and newPendingTransactions with full transactions are
+// left to the upstream
+const (
+	KindNewHeads               = "newHeads"
+	KindLogs                   = "logs"
+	KindNewPendingTransactions = "newPendingTransactions"
+)
+
+type Config struct {
+	ChainName string
+	Ws        string
+	Auth      config.UpstreamAuth
+	WsDialer  *websocket.Dialer // defaults to websocket.DefaultDialer
+	Buffer    int               // notifications queued per subscription, a client falling further behind loses it, defaults to 256
+}
+
+// Subscription is a client subscription served from the upstream subscription of its kind
+type Subscription struct {
+	ID     string
+	kind   string
+	filter *LogFilter // logs only
+	queue  chan json.RawMessage
+}
+
+// Hub subscribes once per kind to the upstream and fans the notifications out to the client subscriptions.
+// A kind is subscribed upstream with its first client subscription and stays subscribed.
+type Hub struct {
+	cfg    Config
+	logger *zap.Logger
+
+	mutex       sync.Mutex
+	subs        map[string]*Subscription
+	kinds       map[string]bool   // kinds to subscribe upstream
+	conn        *websocket.Conn   // nil while disconnected
+	upstreamIDs map[string]string // upstream subscription id => kind
+	pending     map[int]string    // id of an upstream eth_subscribe call => kind
+	requestID   int
+	started     bool
+}
+
+func New(cfg Config, logger *zap.Logger) *Hub {
+	if cfg.Buffer == 0 {
+		cfg.Buffer = 256
+	}
+	return &Hub{
+		cfg:         cfg,
+		logger:      logger.With(zap.String("chain", cfg.ChainName), zap.String("component", "subscription")),
+		subs:        make(map[string]*Subscription),
+		kinds:       make(map[string]bool),
+		upstreamIDs: make(map[string]string),
+		pending:     make(map[int]string),
+	}
+}
+
+// Subscribe serves the eth_subscribe params, the notifications are queued until Start. ok is false for
+// subscriptions the hub does not serve.
+func (h *Hub) Subscribe(params json.RawMessage) (sub *Subscription, ok bool, err error) {
+	var args []json.RawMessage
+	if err = json.Unmarshal(params, &args); err != nil || len(args) == 0 {
+		return nil, false, nil
+	}
+	var kind string
+	if err = json.Unmarshal(args[0], &kind); err != nil {
+		return nil, false, nil
+	}
+
+	sub = &Subscription{kind: kind, queue: make(chan json.RawMessage, h.cfg.Buffer)}
+	switch {
+	case kind == KindNewHeads && len(args) == 1, kind == KindNewPendingTransactions && len(args) == 1:
+	case kind == KindLogs && len(args) <= 2:
+		var rawFilter json.RawMessage
+		if len(args) == 2 {
+			rawFilter = args[1]
+		}
+		if sub.filter, err = ParseLogFilter(rawFilter); err != nil {
+			return nil, true, err
+		}
+	default:
+		return nil, false, nil
+	}
+
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return nil, true, err
+	}
+	sub.ID = "0x" + hex.EncodeToString(b)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.subs[sub.ID] = sub
+	if !h.kinds[kind] {
+		h.kinds[kind] = true
+		if h.conn != nil {
+			h.subscribeUpstream(kind)
+		}
+	}
+	if !h.started {
+		h.started = true
+		go h.run()
+	}
+	return sub, true, nil
+}
+
+// Unsubscribe returns false when the hub does not serve the subscription
+func (h *Hub) Unsubscribe(id string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	sub, ok := h.subs[id]
+	if ok {
+		delete(h.subs, id)
+		close(sub.queue)
+	}
+	return ok
+}
+
+// Start calls send with each notification until the subscription is removed. It is called once the client got
+// the subscription id, clients drop notifications of subscriptions they do not know yet.
+func (s *Subscription) Start(send func(data []byte)) {
+	go s.forward(send)
+}
+
+// forward sends the queued notifications until the subscription is removed
+func (s *Subscription) forward(send func(data []byte)) {
+	for result := range s.queue {
+		notification := jsonrpc.SubscriptionNotification{JsonRpcVersion: "2.0", Method: "eth_subscription"}
+		notification.Params.Subscription = s.ID
+		notification.Params.Result = result
+		data, err := json.Marshal(notification)
+		if err != nil {
+			continue
+		}
+		send(data)
+	}
+}
+
+// run keeps the upstream connection and resubscribes the kinds after reconnecting
+func (h *Hub) run() {
+	for ; ; time.Sleep(time.Second * 3) {
+		conn, err := utils.DialWs(h.cfg.WsDialer, h.cfg.Ws, upstreamauth.Header(h.cfg.Auth))
+		if err != nil {
+			h.logger.Warn("failed to connect the upstream", zap.Error(err))
+			continue
+		}
+
+		h.mutex.Lock()
+		h.conn = conn
+		for kind := range h.kinds {
+			h.subscribeUpstream(kind)
+		}
+		h.mutex.Unlock()
+
+		err = h.read(conn)
+		h.logger.Warn("upstream subscriptions closed", zap.Error(err))
+
+		h.mutex.Lock()
+		h.conn = nil
+		h.upstreamIDs = make(map[string]string)
+		h.pending = make(map[int]string)
+		h.mutex.Unlock()
+		_ = conn.Close()
+	}
+}
+
+// subscribeUpstream must be called with the mutex held, a failed write closes the connection in read
+func (h *Hub) subscribeUpstream(kind string) {
+	h.requestID++
+	h.pending[h.requestID] = kind
+	params := []interface{}{kind}
+	if kind == KindLogs {
+		params = append(params, map[string]interface{}{})
+	}
+	err := h.conn.WriteJSON(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      h.requestID,
+		"method":  "eth_subscribe",
+		"params":  params,
+	})
+	if err != nil {
+		h.logger.Warn("failed to subscribe upstream", zap.String("kind", kind), zap.Error(err))
+		_ = h.conn.Close()
+	}
+}
+
+func (h *Hub) read(conn *websocket.Conn) error {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		notification := jsonrpc.SubscriptionNotification{}
+		if err = json.Unmarshal(message, &notification); err != nil {
+			return err
+		}
+		if notification.Method == "eth_subscription" {
+			h.dispatch(notification.Params.Subscription, notification.Params.Result)
+			continue
+		}
+
+		var resp struct {
+			ID     int             `json:"id"`
+			Result string          `json:"result"`
+			Error  json.RawMessage `json:"error"`
+		}
+		if err = json.Unmarshal(message, &resp); err != nil {
+			continue
+		}
+		h.mutex.Lock()
+		kind, ok := h.pending[resp.ID]
+		delete(h.pending, resp.ID)
+		if ok && len(resp.Error) == 0 {
+			h.upstreamIDs[resp.Result] = kind
+		}
+		h.mutex.Unlock()
+		if ok && len(resp.Error) > 0 {
+			return fmt.Errorf("eth_subscribe %s: %s", kind, resp.Error)
+		}
+	}
+}
+
+// dispatch queues an upstream notification for the matching client subscriptions, a subscription whose
+// queue is full is removed
+func (h *Hub) dispatch(upstreamID string, result json.RawMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	kind, ok := h.upstreamIDs[upstreamID]
+	if !ok {
+		return
+	}
+
+	var log *Log
+	if kind == KindLogs {
+		log = &Log{}
+		if err := json.Unmarshal(result, log); err != nil {
+			return
+		}
+	}
+	for id, sub := range h.subs {
+		if sub.kind != kind || (log != nil && !sub.filter.Match(log)) {
+			continue
+		}
+		select {
+		case sub.queue <- result:
+		default:
+			h.logger.Warn("subscription is too slow, removing it", zap.String("subscription", id))
+			delete(h.subs, id)
+			close(sub.queue)
+		}
+	}
+}
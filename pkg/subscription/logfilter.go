@@ -0,0 +1,95 @@
+package subscription
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LogFilter is the filter of a logs subscription, see https://geth.ethereum.org/docs/interacting-with-geth/rpc/pubsub#logs
+type LogFilter struct {
+	Addresses []string   // any of, empty matches every address
+	Topics    [][]string // per position any of, an empty position matches every topic
+}
+
+// Log is the part of a log the filter reads
+type Log struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+}
+
+// ParseLogFilter reads the second eth_subscribe param of logs subscriptions, addresses and topics are a
+// value or a list and a null topic matches every topic
+func ParseLogFilter(raw json.RawMessage) (*LogFilter, error) {
+	f := &LogFilter{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return f, nil
+	}
+	var params struct {
+		Address json.RawMessage   `json:"address"`
+		Topics  []json.RawMessage `json:"topics"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, errors.Wrap(err, "invalid logs filter")
+	}
+
+	addresses, err := oneOrMany(params.Address)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid address")
+	}
+	for _, address := range addresses {
+		f.Addresses = append(f.Addresses, strings.ToLower(address))
+	}
+	for _, rawTopic := range params.Topics {
+		topics, err := oneOrMany(rawTopic)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid topics")
+		}
+		for i := range topics {
+			topics[i] = strings.ToLower(topics[i])
+		}
+		f.Topics = append(f.Topics, topics)
+	}
+	return f, nil
+}
+
+// oneOrMany reads a string, a list of strings or null
+func oneOrMany(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, err
+	}
+	return many, nil
+}
+
+func (f *LogFilter) Match(log *Log) bool {
+	if len(f.Addresses) > 0 && !contains(f.Addresses, strings.ToLower(log.Address)) {
+		return false
+	}
+	if len(f.Topics) > len(log.Topics) {
+		return false
+	}
+	for i, topics := range f.Topics {
+		if len(topics) > 0 && !contains(topics, strings.ToLower(log.Topics[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
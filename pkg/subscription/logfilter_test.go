@@ -0,0 +1,39 @@
+package subscription
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLogFilterMatch(t *testing.T) {
+	log := &Log{Address: "0xAbC", Topics: []string{"0x01", "0x02", "0x03"}}
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{`null`, true},
+		{`{}`, true},
+		{`{"address":"0xabc"}`, true},
+		{`{"address":["0x1","0xABC"]}`, true},
+		{`{"address":"0xdef"}`, false},
+		{`{"topics":["0x01"]}`, true},
+		{`{"topics":[null,"0x02"]}`, true},
+		{`{"topics":[null,["0x05","0x02"]]}`, true},
+		{`{"topics":["0x02"]}`, false},
+		{`{"topics":[null,null,null,null]}`, false},
+		{`{"address":"0xabc","topics":[["0x01"],null,"0x04"]}`, false},
+	}
+	for _, test := range tests {
+		f, err := ParseLogFilter(json.RawMessage(test.filter))
+		if err != nil {
+			t.Fatalf("%s: %v", test.filter, err)
+		}
+		if match := f.Match(log); match != test.match {
+			t.Errorf("%s: expected %v, got %v", test.filter, test.match, match)
+		}
+	}
+
+	if _, err := ParseLogFilter(json.RawMessage(`{"address":1}`)); err == nil {
+		t.Error("expected an error for a numeric address")
+	}
+}
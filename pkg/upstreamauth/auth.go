@@ -0,0 +1,139 @@
+package upstreamauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"starnet/chain-api/config"
+)
+
+// jwtSecrets caches the secrets read from jwt_secret_file, path => secret
+var jwtSecrets sync.Map
+
+// Validate checks the jwt secret of auth, call it at startup, Apply skips a jwt it can not sign
+func Validate(auth config.UpstreamAuth) error {
+	_, err := jwtSecret(auth)
+	return err
+}
+
+// Apply adds the credentials of auth to the header of an upstream request or websocket dial
+func Apply(header http.Header, auth config.UpstreamAuth) {
+	if auth.Username != "" || auth.Password != "" {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth.Username+":"+auth.Password)))
+	}
+	if auth.Bearer != "" {
+		header.Set("Authorization", "Bearer "+auth.Bearer)
+	}
+	if secret, err := jwtSecret(auth); err == nil && secret != nil {
+		header.Set("Authorization", "Bearer "+signJwt(secret, time.Now()))
+	}
+	for name, value := range auth.Headers {
+		header.Set(name, value)
+	}
+}
+
+// Header returns a new header holding the credentials of auth
+func Header(auth config.UpstreamAuth) http.Header {
+	header := http.Header{}
+	Apply(header, auth)
+	return header
+}
+
+// jwtSecret returns the hex decoded secret of the jwt, nil without one
+func jwtSecret(auth config.UpstreamAuth) ([]byte, error) {
+	secret := auth.JwtSecret
+	if auth.JwtSecretFile != "" {
+		if v, ok := jwtSecrets.Load(auth.JwtSecretFile); ok {
+			return v.([]byte), nil
+		}
+		data, err := os.ReadFile(auth.JwtSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upstream jwt secret file: %w", err)
+		}
+		secret = string(data)
+	}
+	if secret == "" {
+		return nil, nil
+	}
+	decoded, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(secret), "0x"))
+	if err != nil {
+		// the secret itself is not part of the error
+		return nil, errors.New("upstream jwt secret is not hex encoded")
+	}
+	if len(decoded) != 32 {
+		return nil, fmt.Errorf("upstream jwt secret has %d bytes, expected 32", len(decoded))
+	}
+	if auth.JwtSecretFile != "" {
+		jwtSecrets.Store(auth.JwtSecretFile, decoded)
+	}
+	return decoded, nil
+}
+
+// signJwt returns the HS256 token of the engine api authentication, its only claim is the issue time
+func signJwt(secret []byte, now time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"iat":` + strconv.FormatInt(now.Unix(), 10) + `}`))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(header + "." + claims))
+	return header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+const redacted = "xxxxx"
+
+// RedactUrl hides the password, the path segments and the query values of an upstream url, e.g. for logs.
+// Providers put the api key in the path, e.g. /v3/<key> or /<token>/.
+func RedactUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return redacted
+	}
+	if u.Path != "" {
+		segments := strings.Split(u.Path, "/")
+		for i, segment := range segments {
+			if segment != "" {
+				segments[i] = redacted
+			}
+		}
+		u.Path = strings.Join(segments, "/")
+		u.RawPath = ""
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name := range query {
+			query.Set(name, redacted)
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// RedactError hides the url credentials in the message of a failed upstream request
+func RedactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	message := strings.Replace(err.Error(), urlErr.URL, RedactUrl(urlErr.URL), 1)
+	return &redactedError{message: message, err: urlErr.Err}
+}
+
+// redactedError keeps the cause of the url error for errors.Is, e.g. context.Canceled
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string { return e.message }
+
+func (e *redactedError) Unwrap() error { return e.err }
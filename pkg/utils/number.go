@@ -8,7 +8,7 @@ import (
 )
 
 func ToUint64(s string) (uint64, error) {
-	if strings.HasPrefix(s, "0x") {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
 		return strconv.ParseUint(s[2:], 16, 64)
 	} else if strings.Contains(s, ".") {
 		if v, err := strconv.ParseFloat(s, 64); err == nil {
@@ -9,6 +9,7 @@ func Test_ToUint64(t *testing.T) {
 	}{
 		{"1", 1},
 		{"0x1", 1},
+		{"0X1a2B", 0x1a2b},
 		{"3.28594425e+08", 328594425},
 	}
 	for _, test := range tests {
@@ -0,0 +1,26 @@
+package utils
+
+import "strings"
+
+// MatchPath matches a request path against a pattern, a leading "*" matches any prefix and a trailing "*"
+// matches any suffix, otherwise the path must be equal to the pattern. Slashes around both are ignored.
+func MatchPath(pattern, path string) bool {
+	path = "/" + strings.Trim(path, "/")
+	switch {
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(path, strings.TrimRight(pattern[1:], "/"))
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(path, "/"+strings.TrimLeft(pattern[:len(pattern)-1], "/"))
+	}
+	return path == "/"+strings.Trim(pattern, "/")
+}
+
+// MatchAnyPath reports whether path matches one of the patterns, see MatchPath
+func MatchAnyPath(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if MatchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/v1/transactions", "/v1/transactions", true},
+		{"/v1/transactions", "v1/transactions/", true},
+		{"/v1/transactions", "/v1/transactions/simulate", false},
+		{"/v1/transactions*", "/v1/transactions/simulate", true},
+		{"*/broadcasttransaction", "/wallet/broadcasttransaction", true},
+		{"*/broadcasttransaction", "broadcasttransaction", true},
+		{"*/broadcasttransaction", "/wallet/getnowblock", false},
+	}
+	for _, test := range tests {
+		if got := MatchPath(test.pattern, test.path); got != test.want {
+			t.Errorf("MatchPath(%s, %s) = %v, want %v", test.pattern, test.path, got, test.want)
+		}
+	}
+}
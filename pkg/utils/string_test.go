@@ -26,3 +26,21 @@ func TestJqQueryFirst(t *testing.T) {
 		t.Fatal("expected 123, got ", result)
 	}
 }
+
+func TestJqQueryFirstNestedHex(t *testing.T) {
+	query, err := gojq.Parse(".result.number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := JqQueryFirst([]byte(`{"jsonrpc":"2.0","id":1,"result":{"parentHash":"0x01","number":"0x15f5e10"}}`), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockNumber, err := ToUint64(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockNumber != 23027216 {
+		t.Fatal("expected 23027216, got ", blockNumber)
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// DialWs dials a websocket endpoint with dialer, websocket.DefaultDialer when nil, the error contains the response
+// body of a failed handshake
+func DialWs(dialer *websocket.Dialer, urlStr string, requestHeader http.Header) (*websocket.Conn, error) {
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	upstream, resp, err := dialer.Dial(urlStr, requestHeader)
+	if err != nil {
+		respText := "<nil>"
+		if resp != nil {
+			if resp.Body == nil {
+				respText = fmt.Sprintf("%d body: <nil>", resp.StatusCode)
+			} else {
+				if body, err := io.ReadAll(resp.Body); err == nil {
+					respText = fmt.Sprintf("%d body: %s", resp.StatusCode, string(body))
+				} else {
+					respText = fmt.Sprintf("%d body: <nil>: read resp body error: %v", resp.StatusCode, err)
+				}
+			}
+		}
+		return nil, fmt.Errorf("failed to dial websocket: %w\nresponse: %s", err, respText)
+	}
+
+	return upstream, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"starnet/chain-api/pkg/app"
+	"starnet/chain-api/pkg/compression"
 	"starnet/chain-api/pkg/handler"
 
 	"github.com/labstack/echo/v4"
@@ -23,53 +24,66 @@ func NewRouter(app *app.App) *echo.Echo {
 			c.SetRequest(c.Request().WithContext(ctx))
 		},
 	}))
+	compress := compression.Middleware()
 
-	e.POST("/eth/v1/:apiKey", app.EthHttpHandler.Http)
+	e.POST("/eth/v1/:apiKey", app.EthHttpHandler.Http, compress)
 	e.GET("/ws/eth/v1/:apiKey", app.EthWsHandler.Ws)
 
-	e.POST("/polygon/v1/:apiKey", app.PolygonHttpHandler.Http)
+	e.POST("/polygon/v1/:apiKey", app.PolygonHttpHandler.Http, compress)
 	e.GET("/ws/polygon/v1/:apiKey", app.PolygonWsHandler.Ws)
 
-	e.POST("/arbitrum/v1/:apiKey", app.ArbitrumHttpHandler.Http)
+	e.POST("/arbitrum/v1/:apiKey", app.ArbitrumHttpHandler.Http, compress)
 	e.GET("/ws/arbitrum/v1/:apiKey", app.ArbitrumWsHandler.Ws)
 
-	//e.POST("/solana/v1/:apiKey", app.SolanaHttpHandler.Http)
-	//e.GET("/ws/solana/v1/:apiKey", app.SolanaWsHandler.Ws)
+	e.POST("/solana/v1/:apiKey", app.SolanaHttpHandler.Http, compress)
+	e.GET("/ws/solana/v1/:apiKey", app.SolanaWsHandler.Ws)
 
-	e.POST("/hsc/v1/:apiKey", app.HscHttpHandler.Http)
+	e.POST("/hsc/v1/:apiKey", app.HscHttpHandler.Http, compress)
 	e.GET("/ws/hsc/v1/:apiKey", app.HscWsHandler.Ws)
 
-	e.POST("/cosmos/tendermint/v1/:apiKey", app.CosmosHttpHandler.Http)
-	e.GET("/cosmos/tendermint/v1/:apiKey", app.CosmosHttpHandler.TendermintHttp)
+	e.POST("/cosmos/tendermint/v1/:apiKey", app.CosmosHttpHandler.Http, compress)
+	e.GET("/cosmos/tendermint/v1/:apiKey", app.CosmosHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/cosmos/tendermint/v1/:apiKey", app.CosmosWsHandler.Ws)
+	lcdRoute(e, compress, "cosmos", app.CosmosLcdHandler)
 
-	e.POST("/evmos/tendermint/v1/:apiKey", app.EvmosHttpHandler.Http)
-	e.GET("/evmos/tendermint/v1/:apiKey", app.EvmosHttpHandler.TendermintHttp)
+	e.POST("/evmos/tendermint/v1/:apiKey", app.EvmosHttpHandler.Http, compress)
+	e.GET("/evmos/tendermint/v1/:apiKey", app.EvmosHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/evmos/tendermint/v1/:apiKey", app.EvmosWsHandler.Ws)
+	lcdRoute(e, compress, "evmos", app.EvmosLcdHandler)
 
-	e.POST("/kava/tendermint/v1/:apiKey", app.KavaHttpHandler.Http)
-	e.GET("/kava/tendermint/v1/:apiKey", app.KavaHttpHandler.TendermintHttp)
+	e.POST("/kava/tendermint/v1/:apiKey", app.KavaHttpHandler.Http, compress)
+	e.GET("/kava/tendermint/v1/:apiKey", app.KavaHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/kava/tendermint/v1/:apiKey", app.KavaWsHandler.Ws)
+	lcdRoute(e, compress, "kava", app.KavaLcdHandler)
 
-	e.POST("/juno/tendermint/v1/:apiKey", app.JunoHttpHandler.Http)
-	e.GET("/juno/tendermint/v1/:apiKey", app.JunoHttpHandler.TendermintHttp)
+	e.POST("/juno/tendermint/v1/:apiKey", app.JunoHttpHandler.Http, compress)
+	e.GET("/juno/tendermint/v1/:apiKey", app.JunoHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/juno/tendermint/v1/:apiKey", app.JunoWsHandler.Ws)
+	lcdRoute(e, compress, "juno", app.JunoLcdHandler)
 
-	e.POST("/umee/tendermint/v1/:apiKey", app.UmeeHttpHandler.Http)
-	e.GET("/umee/tendermint/v1/:apiKey", app.UmeeHttpHandler.TendermintHttp)
+	e.POST("/umee/tendermint/v1/:apiKey", app.UmeeHttpHandler.Http, compress)
+	e.GET("/umee/tendermint/v1/:apiKey", app.UmeeHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/umee/tendermint/v1/:apiKey", app.UmeeWsHandler.Ws)
+	lcdRoute(e, compress, "umee", app.UmeeLcdHandler)
 
-	e.POST("/gravity/tendermint/v1/:apiKey", app.GravityHttpHandler.Http)
-	e.GET("/gravity/tendermint/v1/:apiKey", app.GravityHttpHandler.TendermintHttp)
+	e.POST("/gravity/tendermint/v1/:apiKey", app.GravityHttpHandler.Http, compress)
+	e.GET("/gravity/tendermint/v1/:apiKey", app.GravityHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/gravity/tendermint/v1/:apiKey", app.GravityWsHandler.Ws)
+	lcdRoute(e, compress, "gravity", app.GravityLcdHandler)
 
-	e.POST("/okc/tendermint/v1/:apiKey", app.OKCHttpHandler.Http)
-	e.GET("/okc/tendermint/v1/:apiKey", app.OKCHttpHandler.TendermintHttp)
+	e.POST("/okc/tendermint/v1/:apiKey", app.OKCHttpHandler.Http, compress)
+	e.GET("/okc/tendermint/v1/:apiKey", app.OKCHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/okc/tendermint/v1/:apiKey", app.OKCWsHandler.Ws)
+	lcdRoute(e, compress, "okc", app.OKCLcdHandler)
 
-	e.POST("/irisnet/tendermint/v1/:apiKey", app.IRISnetHttpHandler.Http)
-	e.GET("/irisnet/tendermint/v1/:apiKey", app.IRISnetHttpHandler.TendermintHttp)
+	e.POST("/irisnet/tendermint/v1/:apiKey", app.IRISnetHttpHandler.Http, compress)
+	e.GET("/irisnet/tendermint/v1/:apiKey", app.IRISnetHttpHandler.TendermintHttp, compress)
 	e.GET("/ws/irisnet/tendermint/v1/:apiKey", app.IRISnetWsHandler.Ws)
+	lcdRoute(e, compress, "irisnet", app.IRISnetLcdHandler)
+
+	for name, h := range app.GrpcHandlers {
+		e.POST("/"+name+"/grpc-web/v1/:apiKey/*", h.GrpcWeb)
+	}
 
 	e.Any("/ipfs/*", app.IPFSHandler.Proxy)
 
@@ -80,20 +94,31 @@ func NewRouter(app *app.App) *echo.Echo {
 			if err != nil {
 				panic(err)
 			}
-			switch rpcConfig.ChainType {
-			case "tron":
-				e.Any("/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey, rpcHandler.Http)
-				e.Any("/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey+"/*", rpcHandler.Http)
-				e.Any("/ew_rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey, rpcHandler.ExtraWriteHttp)
-				e.Any("/ew_rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey+"/*", rpcHandler.ExtraWriteHttp)
-			default:
+			var hasWs, hasExtraWrite bool
+			for _, node := range rpcConfig.Nodes {
+				hasWs = hasWs || node.Ws != ""
+				hasExtraWrite = hasExtraWrite || node.ExtraWrite != ""
+			}
+			if hasWs {
 				e.Any("/ws/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey, rpcHandler.Ws)
 				e.Any("/ws/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey+"/*", rpcHandler.Ws)
-				e.Any("/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey, rpcHandler.Http)
-				e.Any("/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey+"/*", rpcHandler.Http)
+			}
+			e.Any("/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey, rpcHandler.Http, compress)
+			e.Any("/rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey+"/*", rpcHandler.Http, compress)
+			if hasExtraWrite {
+				e.Any("/ew_rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey, rpcHandler.ExtraWriteHttp, compress)
+				e.Any("/ew_rpc/"+rpcConfig.ChainName+"/"+app.RpcConfig.ApiKey+"/*", rpcHandler.ExtraWriteHttp, compress)
 			}
 		}
 	}
 
 	return e
 }
+
+// lcdRoute registers the cosmos sdk rest api of a chain when its lcd upstream is configured
+func lcdRoute(e *echo.Echo, compress echo.MiddlewareFunc, chainName string, h app.LcdHandler) {
+	if h == nil {
+		return
+	}
+	e.Any("/"+chainName+"/lcd/v1/:apiKey/*", h.Lcd, compress)
+}